@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/midbel/log"
 )
@@ -18,9 +21,25 @@ func main() {
 		in     = flag.String("i", input, "input pattern")
 		out    = flag.String("o", output, "output pattern")
 		filter = flag.String("f", "", "filter log entry")
+		lint   = flag.Bool("l", false, "lint input pattern before reading")
+		grok   = flag.Bool("g", false, "input pattern is a grok expression")
+		infer  = flag.Int("x", 0, "infer input pattern from the first n lines")
+		anchor = flag.String("a", "", "anchor of relative timestamps (boot or RFC3339 time)")
 	)
 	flag.Parse()
 
+	if *grok {
+		pat, ws, err := log.ConvertGrok(*in, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, w := range ws {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", *in, w)
+		}
+		*in = pat
+	}
+
 	r, err := os.Open(flag.Arg(0))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -28,7 +47,55 @@ func main() {
 	}
 	defer r.Close()
 
-	rs, err := log.NewReader(r, *in, *filter)
+	if *infer > 0 {
+		var lines []string
+		for s := bufio.NewScanner(r); len(lines) < *infer && s.Scan(); {
+			lines = append(lines, s.Text())
+		}
+		pat, err := log.Infer(lines)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, pat)
+		*in = pat
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	if *lint {
+		p, err := log.Compile(*in)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, w := range log.Lint(p) {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", *in, w)
+		}
+	}
+
+	i, err := r.Stat()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	options := []log.Option{log.WithYear(i.ModTime())}
+	if *anchor != "" {
+		var when time.Time
+		if *anchor == "boot" {
+			when, err = log.BootTime()
+		} else {
+			when, err = time.Parse(time.RFC3339, *anchor)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		options = append(options, log.WithAnchor(when))
+	}
+	rs, err := log.NewReader(r, *in, *filter, options...)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -33,6 +33,15 @@ type Log struct {
 	URL     string
 	Pattern string `toml:"format"`
 	Line    int64
+	Zone    string `toml:"timezone"`
+	Locale  string
+
+	pattern *log.Pattern
+}
+
+type Macro struct {
+	Name    string
+	Pattern string `toml:"format"`
 }
 
 func (g Log) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -52,13 +61,15 @@ func (g Log) serveInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	c := struct {
-		File    string    `json:"file"`
-		Size    int64     `json:"size"`
-		ModTime time.Time `json:"modtime"`
+		File    string     `json:"file"`
+		Size    int64      `json:"size"`
+		ModTime time.Time  `json:"modtime"`
+		Schema  log.Schema `json:"schema"`
 	}{
 		File:    filepath.Clean(g.File),
 		Size:    i.Size(),
 		ModTime: i.ModTime(),
+		Schema:  g.pattern.Schema(),
 	}
 	json.NewEncoder(w).Encode(c)
 }
@@ -85,16 +96,22 @@ func (g Log) readEntries(limit int, filter string) ([]log.Entry, error) {
 	if limit <= 0 {
 		limit = int(g.Line)
 	}
+	i, err := os.Stat(g.File)
+	if err != nil {
+		return nil, err
+	}
 	r, err := tail.Tail(g.File, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer r.Close()
 
-	rs, err := log.NewReader(r, g.Pattern, "")
+	rs, err := log.NewPatternReader(r, g.pattern, "")
 	if err != nil {
 		return nil, err
 	}
+	rs.SetYear(i.ModTime())
+
 	es := make([]log.Entry, 0, g.Line)
 	for {
 		e, err := rs.Read()
@@ -132,24 +149,48 @@ func main() {
 		Query int `toml:"max-query"`
 		Site Site
 		Logs []Log `toml:"log"`
+		Macros []Macro `toml:"macro"`
 	}{}
 	if err := toml.DecodeFile(flag.Arg(0), &config); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	macros := make(map[string]string)
+	for _, m := range config.Macros {
+		macros[m.Name] = m.Pattern
+	}
 
 	if config.Query <= 0 {
 		config.Query = MaxQuery
 	}
 	sema := semaphore.NewWeighted(int64(config.Query))
 
-	for _, g := range config.Logs {
+	for j := range config.Logs {
+		g := &config.Logs[j]
 		if i, err := os.Stat(g.File); err != nil || i.IsDir() {
 			fmt.Fprintf(os.Stderr, "%s: file does not exist! (%v)\n", g.File, err)
 			os.Exit(1)
 		}
-		http.Handle(g.URL, wrapHandler(sema, g))
-		http.Handle(fmt.Sprintf("%s/detail", g.URL), wrapHandler(sema, g))
+		options := []log.Option{log.WithMacros(macros)}
+		if g.Zone != "" {
+			loc, err := time.LoadLocation(g.Zone)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: invalid timezone! (%v)\n", g.File, err)
+				os.Exit(1)
+			}
+			options = append(options, log.WithLocation(loc))
+		}
+		if g.Locale != "" {
+			options = append(options, log.WithLocale(g.Locale))
+		}
+		p, err := log.Compile(g.Pattern, options...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid pattern! (%v)\n", g.File, err)
+			os.Exit(1)
+		}
+		g.pattern = p
+		http.Handle(g.URL, wrapHandler(sema, *g))
+		http.Handle(fmt.Sprintf("%s/detail", g.URL), wrapHandler(sema, *g))
 	}
 	http.Handle("/sources", viewSources(config.Logs))
 	if url, handler := config.Site.Handle(); url != "" && handler != nil {
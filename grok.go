@@ -0,0 +1,431 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ConvertGrok translates a grok expression into a read pattern. The grok
+// patterns referenced by the expression are looked up first in patterns then
+// in the standard grok library.
+//
+// References with an equivalent specifier (eg: %{IP:client}, %{LOGLEVEL}) are
+// translated into that specifier, the others become a %r specifier with a
+// named group. When the text between references is not a plain literal, the
+// whole expression is translated into a single %r specifier.
+//
+// The returned warnings report the constructs that could not be translated as
+// is. An error is returned if the expression uses constructs without
+// equivalent (eg: unknown pattern, lookaround).
+func ConvertGrok(expr string, patterns map[string]string) (string, []Warning, error) {
+	g := grok{
+		patterns: patterns,
+		kinds:    make(map[rune]bool),
+	}
+	return g.convert(expr)
+}
+
+var grokRef = regexp.MustCompile(`%\{(\w+)(?::([^:}]+))?(?::(\w+))?\}`)
+
+type grok struct {
+	patterns map[string]string
+	kinds    map[rune]bool
+	warnings []Warning
+	errors   []string
+}
+
+type grokSpecifier struct {
+	kind    rune
+	pattern string
+	// named is set when the reference can be translated only if it is not
+	// captured into a field
+	named bool
+	// last is set when the reference has to end the expression
+	last bool
+}
+
+var grokSpecifiers = map[string]grokSpecifier{
+	"HTTPDATE":        {kind: 't', pattern: "%t(%d/%b/%y:%H:%M:%S %Z)"},
+	"SYSLOGTIMESTAMP": {kind: 't', pattern: "%t(%b %e %H:%M:%S)"},
+	"LOGLEVEL":        {kind: 'l', pattern: "%l"},
+	"IP":              {kind: 'h', pattern: "@(%h(%4)|%h(%6))"},
+	"IPV4":            {kind: 'h', pattern: "%h(%4)"},
+	"IPV6":            {kind: 'h', pattern: "%h(%6)"},
+	"IPORHOST":        {kind: 'h', pattern: "%h(%f)"},
+	"HOSTNAME":        {kind: 'h', pattern: "%h(%f)"},
+	"SYSLOGHOST":      {kind: 'h', pattern: "%h(%f)"},
+	"PROG":            {kind: 'n', pattern: "%n([:name:]./)"},
+	"USER":            {kind: 'u', pattern: "%u([:name:].@)"},
+	"USERNAME":        {kind: 'u', pattern: "%u([:name:].@)"},
+	"GREEDYDATA":      {kind: 'm', pattern: "%m", last: true},
+	"WORD":            {kind: 'w', pattern: "%w", named: true},
+	"NOTSPACE":        {kind: 'w', pattern: "%w", named: true},
+	"DATA":            {kind: '*', pattern: "%*", named: true},
+	"SPACE":           {kind: 'b', pattern: "%b", named: true},
+}
+
+func (g *grok) convert(expr string) (string, []Warning, error) {
+	var (
+		buf   strings.Builder
+		refs  = grokRef.FindAllStringSubmatchIndex(expr, -1)
+		prev  int
+		plain = true
+	)
+	for i, ix := range refs {
+		glue, ok := grokLiteral(expr[prev:ix[0]])
+		if !ok {
+			plain = false
+			break
+		}
+		buf.WriteString(glue)
+		var (
+			name  = expr[ix[2]:ix[3]]
+			field = grokGroup(expr, ix, 4)
+			conv  = grokGroup(expr, ix, 6)
+			last  = i == len(refs)-1 && ix[1] == len(expr)
+		)
+		if conv != "" {
+			g.warn(ix[0], "type conversion %s of %s ignored", conv, name)
+		}
+		if spec, ok := g.specifier(name, field, last); ok {
+			if field != "" && !spec.named && field != kindName(spec.kind) {
+				g.warn(ix[0], "%s stored in %s instead of field %s", name, kindName(spec.kind), field)
+			}
+			buf.WriteString(spec.pattern)
+		} else {
+			rx := g.expand(name, g.field(ix[0], field), nil)
+			buf.WriteString("%r(" + rx + ")")
+		}
+		prev = ix[1]
+	}
+	if plain {
+		glue, ok := grokLiteral(expr[prev:])
+		if !ok {
+			plain = false
+		}
+		buf.WriteString(glue)
+	}
+	if !plain {
+		g.warnings, g.errors = nil, nil
+		g.warn(0, "expression translated into a single regular expression")
+		buf.Reset()
+		buf.WriteString("%r(" + g.regex(expr, nil) + ")")
+	}
+	if len(g.errors) > 0 {
+		return "", g.warnings, fmt.Errorf("%w(grok): %s", ErrSyntax, strings.Join(g.errors, "; "))
+	}
+	pattern := buf.String()
+	if pattern == "" {
+		return "", g.warnings, fmt.Errorf("%w(grok): empty expression", ErrSyntax)
+	}
+	if _, err := Compile(pattern); err != nil {
+		return "", g.warnings, err
+	}
+	return pattern, g.warnings, nil
+}
+
+func (g *grok) specifier(name, field string, last bool) (grokSpecifier, bool) {
+	if _, ok := g.patterns[name]; ok {
+		return grokSpecifier{}, false
+	}
+	spec, ok := grokSpecifiers[name]
+	if !ok {
+		switch name {
+		case "POSINT", "NONNEGINT", "INT", "NUMBER":
+			if field != "pid" {
+				return spec, false
+			}
+			spec = grokSpecifier{kind: 'p', pattern: "%p"}
+		default:
+			return spec, false
+		}
+	}
+	if spec.named && field != "" {
+		return spec, false
+	}
+	if spec.last && !last {
+		return spec, false
+	}
+	switch spec.kind {
+	case 'w', '*', 'b':
+	default:
+		if g.kinds[spec.kind] {
+			return spec, false
+		}
+		g.kinds[spec.kind] = true
+	}
+	return spec, true
+}
+
+// regex replaces the grok references found in expr by the regular
+// expressions they refer to.
+func (g *grok) regex(expr string, stack []string) string {
+	var (
+		buf  strings.Builder
+		prev int
+	)
+	for _, ix := range grokRef.FindAllStringSubmatchIndex(expr, -1) {
+		buf.WriteString(g.check(expr[prev:ix[0]]))
+		var (
+			name  = expr[ix[2]:ix[3]]
+			field = grokGroup(expr, ix, 4)
+		)
+		if len(stack) == 0 {
+			if conv := grokGroup(expr, ix, 6); conv != "" {
+				g.warn(ix[0], "type conversion %s of %s ignored", conv, name)
+			}
+			field = g.field(ix[0], field)
+		} else {
+			field = grokField(field)
+		}
+		buf.WriteString(g.expand(name, field, stack))
+		prev = ix[1]
+	}
+	buf.WriteString(g.check(expr[prev:]))
+	return buf.String()
+}
+
+func (g *grok) expand(name, field string, stack []string) string {
+	for _, s := range stack {
+		if s == name {
+			g.errors = append(g.errors, fmt.Sprintf("recursive pattern %s", name))
+			return ""
+		}
+	}
+	def, ok := g.patterns[name]
+	if !ok {
+		def, ok = grokPatterns[name]
+	}
+	if !ok {
+		g.errors = append(g.errors, fmt.Sprintf("unknown pattern %s", name))
+		return ""
+	}
+	rx := g.regex(def, append(stack, name))
+	if field == "" {
+		return "(?:" + rx + ")"
+	}
+	return "(?P<" + field + ">" + rx + ")"
+}
+
+// check rewrites the Oniguruma named groups into groups understood by the
+// regexp package and reports the constructs that have no equivalent.
+func (g *grok) check(rx string) string {
+	var (
+		buf   strings.Builder
+		class bool
+	)
+	for i := 0; i < len(rx); i++ {
+		c := rx[i]
+		switch {
+		case c == '\\' && i+1 < len(rx):
+			switch n := rx[i+1]; {
+			case n >= '1' && n <= '9', n == 'k' || n == 'g':
+				g.errors = append(g.errors, fmt.Sprintf("back reference \\%c not supported", n))
+			}
+			buf.WriteByte(c)
+			i++
+			c = rx[i]
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+			if strings.HasPrefix(rx[i:], "[]") || strings.HasPrefix(rx[i:], "[^]") {
+				x := strings.IndexByte(rx[i:], ']')
+				buf.WriteString(rx[i : i+x])
+				i += x
+				c = rx[i]
+			}
+		case strings.HasPrefix(rx[i:], "(?<") && !strings.HasPrefix(rx[i:], "(?<=") && !strings.HasPrefix(rx[i:], "(?<!"):
+			buf.WriteString("(?P<")
+			i += 2
+			continue
+		case c == '(':
+			for _, x := range []string{"(?=", "(?!", "(?<=", "(?<!", "(?>"} {
+				if strings.HasPrefix(rx[i:], x) {
+					g.errors = append(g.errors, fmt.Sprintf("construct %s) not supported", x))
+				}
+			}
+		case c == '+' && i > 0 && strings.IndexByte("*+?}", rx[i-1]) >= 0:
+			g.errors = append(g.errors, fmt.Sprintf("possessive quantifier %s not supported", rx[i-1:i+1]))
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+func (g *grok) field(pos int, field string) string {
+	str := grokField(field)
+	if str != field {
+		g.warn(pos, "field %s renamed %s", field, str)
+	}
+	return str
+}
+
+func (g *grok) warn(pos int, msg string, args ...interface{}) {
+	g.warnings = append(g.warnings, Warning{Pos: pos, Msg: fmt.Sprintf(msg, args...)})
+}
+
+func grokGroup(expr string, ix []int, i int) string {
+	if ix[i] < 0 {
+		return ""
+	}
+	return expr[ix[i]:ix[i+1]]
+}
+
+// grokField turns the name of a grok field into a valid group name, eg:
+// [http][method] becomes http_method.
+func grokField(field string) string {
+	field = strings.Trim(field, "[]")
+	return strings.Map(func(r rune) rune {
+		if isLetter(r) || isDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ReplaceAll(field, "][", "_"))
+}
+
+// grokLiteral translates the regular expression found between two grok
+// references into literals of a read pattern. It fails if the expression is
+// not made of literals and blanks.
+func grokLiteral(glue string) (string, bool) {
+	var buf strings.Builder
+	literal := func(c byte) {
+		switch c {
+		case '%':
+			buf.WriteString("%%")
+		case '@', '\\', '*', '(', ')', '|':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	for i := 0; i < len(glue); i++ {
+		c := glue[i]
+		switch {
+		case c == '\\' && i+1 < len(glue):
+			i++
+			c = glue[i]
+			if c == 's' {
+				if i+1 < len(glue) && (glue[i+1] == '+' || glue[i+1] == '*') {
+					i++
+				}
+				buf.WriteString("%b")
+				continue
+			}
+			if isLetter(rune(c)) || isDigit(rune(c)) {
+				return "", false
+			}
+			literal(c)
+		case c == ' ' && i+1 < len(glue) && (glue[i+1] == '+' || glue[i+1] == '*'):
+			if glue[i+1] == '+' {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString("%b")
+			i++
+		case strings.IndexByte(".^$*+?()[]{}|", c) >= 0:
+			return "", false
+		default:
+			literal(c)
+		}
+	}
+	return buf.String(), true
+}
+
+func kindName(kind rune) string {
+	switch kind {
+	case 't':
+		return "time"
+	case 'h':
+		return "host"
+	case 'l':
+		return "level"
+	case 'm':
+		return "message"
+	case 'p':
+		return "pid"
+	case 'n':
+		return "process"
+	case 'u':
+		return "user"
+	default:
+		return "words"
+	}
+}
+
+// grokPatterns is the standard grok library rewritten for the regexp package
+// when the original definitions rely on constructs it does not support.
+var grokPatterns = map[string]string{
+	"USERNAME":           `[a-zA-Z0-9._-]+`,
+	"USER":               `%{USERNAME}`,
+	"EMAILLOCALPART":     `[a-zA-Z][a-zA-Z0-9_.+=:-]+`,
+	"EMAILADDRESS":       `%{EMAILLOCALPART}@%{HOSTNAME}`,
+	"INT":                `(?:[+-]?(?:[0-9]+))`,
+	"BASE10NUM":          `(?:[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+))`,
+	"NUMBER":             `(?:%{BASE10NUM})`,
+	"BASE16NUM":          `(?:0[xX]?[0-9a-fA-F]+)`,
+	"BASE16FLOAT":        `(?:[+-]?(?:0x)?(?:[0-9A-Fa-f]+(?:\.[0-9A-Fa-f]*)?|\.[0-9A-Fa-f]+))`,
+	"POSINT":             `\b(?:[1-9][0-9]*)\b`,
+	"NONNEGINT":          `\b(?:[0-9]+)\b`,
+	"WORD":               `\b\w+\b`,
+	"NOTSPACE":           `\S+`,
+	"SPACE":              `\s*`,
+	"DATA":               `.*?`,
+	"GREEDYDATA":         `.*`,
+	"QUOTEDSTRING":       `(?:"(?:\\.|[^\\"])*"|'(?:\\.|[^\\'])*'|` + "`(?:\\\\.|[^\\\\`])*`)",
+	"QS":                 `%{QUOTEDSTRING}`,
+	"UUID":               `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"MAC":                `(?:%{CISCOMAC}|%{WINDOWSMAC}|%{COMMONMAC})`,
+	"CISCOMAC":           `(?:(?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4})`,
+	"WINDOWSMAC":         `(?:(?:[A-Fa-f0-9]{2}-){5}[A-Fa-f0-9]{2})`,
+	"COMMONMAC":          `(?:(?:[A-Fa-f0-9]{2}:){5}[A-Fa-f0-9]{2})`,
+	"IPV6":               `(?:(?:[0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}|(?:[0-9A-Fa-f]{1,4}:){1,7}:|(?:[0-9A-Fa-f]{1,4}:){1,6}(?::[0-9A-Fa-f]{1,4}){1,6}|:(?::[0-9A-Fa-f]{1,4}){1,7}|::)(?:%.+)?`,
+	"IPV4":               `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})`,
+	"IP":                 `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":           `\b(?:[0-9A-Za-z][0-9A-Za-z-]{0,62})(?:\.(?:[0-9A-Za-z][0-9A-Za-z-]{0,62}))*(?:\.?|\b)`,
+	"IPORHOST":           `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":           `%{IPORHOST}:%{POSINT}`,
+	"PATH":               `(?:%{UNIXPATH}|%{WINPATH})`,
+	"UNIXPATH":           `(?:/[\w_%!$@:.,+~-]*)+`,
+	"TTY":                `(?:/dev/(?:pts|tty(?:[pq])?)(?:\w+)?/?(?:[0-9]+))`,
+	"WINPATH":            `(?:[A-Za-z]+:|\\)(?:\\[^\\?*]*)+`,
+	"URIPROTO":           `[A-Za-z][A-Za-z0-9+\-.]+`,
+	"URIHOST":            `%{IPORHOST}(?::%{POSINT})?`,
+	"URIPATH":            `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":           `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":       `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":                `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+	"MONTH":              `\b(?:[Jj]an(?:uary|uar)?|[Ff]eb(?:ruary|ruar)?|[Mm](?:a|ä)?r(?:ch|z)?|[Aa]pr(?:il)?|[Mm]a(?:y|i)?|[Jj]un(?:e|i)?|[Jj]ul(?:y|i)?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo](?:c|k)?t(?:ober)?|[Nn]ov(?:ember)?|[Dd]e(?:c|z)(?:ember)?)\b`,
+	"MONTHNUM":           `(?:0?[1-9]|1[0-2])`,
+	"MONTHNUM2":          `(?:0[1-9]|1[0-2])`,
+	"MONTHDAY":           `(?:(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9])`,
+	"DAY":                `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":               `(?:\d\d){1,2}`,
+	"HOUR":               `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":             `(?:[0-5][0-9])`,
+	"SECOND":             `(?:(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?)`,
+	"TIME":               `%{HOUR}:%{MINUTE}(?::%{SECOND})`,
+	"DATE_US":            `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+	"DATE_EU":            `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+	"ISO8601_TIMEZONE":   `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"ISO8601_SECOND":     `%{SECOND}`,
+	"TIMESTAMP_ISO8601":  `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"DATE":               `%{DATE_US}|%{DATE_EU}`,
+	"DATESTAMP":          `%{DATE}[- ]%{TIME}`,
+	"TZ":                 `(?:[APMCE][SD]T|UTC)`,
+	"DATESTAMP_RFC822":   `%{DAY} %{MONTH} %{MONTHDAY} %{YEAR} %{TIME} %{TZ}`,
+	"DATESTAMP_RFC2822":  `%{DAY}, %{MONTHDAY} %{MONTH} %{YEAR} %{TIME} %{ISO8601_TIMEZONE}`,
+	"DATESTAMP_OTHER":    `%{DAY} %{MONTH} %{MONTHDAY} %{TIME} %{TZ} %{YEAR}`,
+	"DATESTAMP_EVENTLOG": `%{YEAR}%{MONTHNUM2}%{MONTHDAY}%{HOUR}%{MINUTE}%{SECOND}`,
+	"SYSLOGTIMESTAMP":    `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"PROG":               `[\x21-\x5a\x5c\x5e-\x7e]+`,
+	"SYSLOGPROG":         `%{PROG:program}(?:\[%{POSINT:pid}\])?`,
+	"SYSLOGHOST":         `%{IPORHOST}`,
+	"SYSLOGFACILITY":     `<%{NONNEGINT:facility}.%{NONNEGINT:priority}>`,
+	"HTTPDATE":           `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGBASE":         `%{SYSLOGTIMESTAMP:timestamp} (?:%{SYSLOGFACILITY} )?%{SYSLOGHOST:logsource} %{SYSLOGPROG}:`,
+	"COMMONAPACHELOG":    `%{IPORHOST:clientip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{NUMBER:response} (?:%{NUMBER:bytes}|-)`,
+	"COMBINEDAPACHELOG":  `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+	"LOGLEVEL":           `(?:[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn?(?:ing)?|WARN?(?:ING)?|[Ee]rr?(?:or)?|ERR?(?:OR)?|[Cc]rit?(?:ical)?|CRIT?(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|EMERG(?:ENCY)?|[Ee]merg(?:ency)?)`,
+}
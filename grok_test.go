@@ -0,0 +1,127 @@
+package log
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestConvertGrok(t *testing.T) {
+	data := []struct {
+		Expr     string
+		Pattern  string
+		Warnings int
+	}{
+		{
+			Expr:     `%{SYSLOGHOST:host} %{PROG}\[%{POSINT:pid}\]: %{GREEDYDATA:msg}`,
+			Pattern:  "%h(%f) %n([:name:]./)[%p]: %m",
+			Warnings: 1,
+		},
+		{
+			Expr:     `%{SYSLOGTIMESTAMP:ts} %{SYSLOGHOST:host} %{PROG}\[%{POSINT:pid}\]: %{GREEDYDATA:msg}`,
+			Pattern:  "%t(%b %e %H:%M:%S) %h(%f) %n([:name:]./)[%p]: %m",
+			Warnings: 2,
+		},
+		{
+			Expr:     `%{IP:client} %{WORD:method} %{GREEDYDATA}`,
+			Pattern:  `@(%h(%4)|%h(%6)) %r((?P<method>\b\w+\b)) %m`,
+			Warnings: 1,
+		},
+		{
+			Expr:    `%{LOGLEVEL} %{MYNUM:took}ms %{GREEDYDATA}`,
+			Pattern: `%l %r((?P<took>\d+))ms %m`,
+		},
+		{
+			Expr:     `%{WORD}(a|b) %{GREEDYDATA}`,
+			Pattern:  `%r((?:\b\w+\b)(a|b) (?:.*))`,
+			Warnings: 1,
+		},
+	}
+	patterns := map[string]string{"MYNUM": `\d+`}
+	for _, d := range data {
+		got, ws, err := ConvertGrok(d.Expr, patterns)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", d.Expr, err)
+			continue
+		}
+		if got != d.Pattern {
+			t.Errorf("%s: pattern mismatched! want %s, got %s", d.Expr, d.Pattern, got)
+		}
+		if len(ws) != d.Warnings {
+			t.Errorf("%s: warnings mismatched! want %d, got %d (%v)", d.Expr, d.Warnings, len(ws), ws)
+		}
+		if _, err := Compile(got); err != nil {
+			t.Errorf("%s: pattern does not compile: %s", got, err)
+		}
+	}
+}
+
+func TestConvertGrokError(t *testing.T) {
+	for _, expr := range []string{`%{FOO:bar}`, `(?=x)%{WORD}`} {
+		if _, _, err := ConvertGrok(expr, nil); !errors.Is(err, ErrSyntax) {
+			t.Errorf("%s: expected ErrSyntax, got %v", expr, err)
+		}
+	}
+}
+
+func TestConvertGrokMatch(t *testing.T) {
+	data := []struct {
+		Expr string
+		Line string
+		Want Entry
+	}{
+		{
+			Expr: `%{WORD:method} %{IP:client}`,
+			Line: "GET 10.0.0.1",
+			Want: Entry{
+				Host:     "10.0.0.1",
+				Fields:   map[string]string{"method": "GET"},
+				Branches: []string{"0"},
+			},
+		},
+		{
+			Expr: `%{WORD:method} %{IP:client}`,
+			Line: "GET 2001:db8::1",
+			Want: Entry{
+				Host:     "2001:db8::1",
+				Fields:   map[string]string{"method": "GET"},
+				Branches: []string{"1"},
+			},
+		},
+		{
+			Expr: `%{COMBINEDAPACHELOG}`,
+			Line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`,
+			Want: Entry{
+				Fields: map[string]string{
+					"clientip":    "127.0.0.1",
+					"ident":       "-",
+					"auth":        "frank",
+					"timestamp":   "10/Oct/2000:13:55:36 -0700",
+					"verb":        "GET",
+					"request":     "/apache_pb.gif",
+					"httpversion": "1.0",
+					"response":    "200",
+					"bytes":       "2326",
+					"referrer":    `"http://www.example.com/start.html"`,
+					"agent":       `"Mozilla/4.08"`,
+				},
+			},
+		},
+	}
+	for _, d := range data {
+		pattern, _, err := ConvertGrok(d.Expr, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", d.Expr, err)
+			continue
+		}
+		got, err := MustCompile(pattern).MatchString(d.Line)
+		if err != nil {
+			t.Errorf("%s: unexpected error matching %q: %s", pattern, d.Line, err)
+			continue
+		}
+		got.Line = ""
+		if !reflect.DeepEqual(got, d.Want) {
+			t.Errorf("%s: entry mismatched!\nwant: %+v\ngot:  %+v", d.Expr, d.Want, got)
+		}
+	}
+}
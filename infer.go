@@ -0,0 +1,392 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Infer proposes a pattern matching all the given sample lines. Timestamps,
+// IP addresses, pids between brackets and levels are recognized at the head of
+// the lines, the free text that follows being captured as message. The proposed
+// pattern is compiled with the given options and matched against every sample
+// before being returned.
+func Infer(samples []string, options ...Option) (string, error) {
+	if len(samples) == 0 {
+		return "", fmt.Errorf("%w: no sample to infer pattern from", ErrPattern)
+	}
+	cfg, err := makeConfig(options)
+	if err != nil {
+		return "", err
+	}
+	layouts, err := inferLayouts(cfg)
+	if err != nil {
+		return "", err
+	}
+	lines := make([][]token, len(samples))
+	for i, s := range samples {
+		lines[i] = tokenize(strings.TrimRight(s, "\r\n"), layouts, cfg)
+	}
+	pattern := inferPattern(lines, cfg)
+
+	p, err := Compile(pattern, options...)
+	if err != nil {
+		return "", err
+	}
+	for i, s := range samples {
+		if _, err := p.MatchString(s); err != nil {
+			return "", fmt.Errorf("%w: %s does not match sample %d", ErrPattern, pattern, i+1)
+		}
+	}
+	return pattern, nil
+}
+
+// token is a piece of a sample line. kind is the specifier that can parse it,
+// 'b' for blanks and 0 for punctuation that is kept as literal. args holds the
+// arguments of the specifier that parse the text of the token.
+type token struct {
+	kind rune
+	text string
+	args []string
+}
+
+// column is a token found at the same position in all the samples.
+type column struct {
+	kind  rune
+	args  []string
+	texts []string
+}
+
+type layout struct {
+	pattern string
+	parse   whenfunc
+}
+
+var (
+	inferDates = []string{
+		"%y-%m-%d",
+		"%y/%m/%d",
+		"%d/%m/%y",
+		"%m/%d/%y",
+		"%d/%b/%y",
+		"%d-%b-%y",
+		"%d %b %y",
+		"%a %b %e",
+		"%b %e",
+	}
+	inferClocks    = []string{"T", " ", ":"}
+	inferFractions = []string{"", ".%f", ",%f"}
+	inferZones     = []string{"", "%Z", " %Z"}
+	inferOthers    = []string{
+		"%a %b %e %H:%M:%S %y",
+		"%a, %d %b %y %H:%M:%S %Z",
+		"%-m/%-d/%y %-K:%M:%S %p",
+	}
+)
+
+// inferLayouts builds the time patterns recognized in the samples. When several
+// patterns parse the same text, the first one is preferred.
+func inferLayouts(cfg *config) ([]layout, error) {
+	var patterns []string
+	for _, d := range append(inferDates, "") {
+		for _, c := range inferClocks {
+			clock := d + c + "%H:%M:%S"
+			if d == "" {
+				clock = "%H:%M:%S"
+			}
+			for _, f := range inferFractions {
+				for _, z := range inferZones {
+					patterns = append(patterns, clock+f+z)
+				}
+			}
+			if d == "" {
+				break
+			}
+		}
+	}
+	patterns = append(patterns, inferOthers...)
+
+	ls := make([]layout, 0, len(patterns))
+	for _, p := range patterns {
+		parse, err := parseTimePattern(p, cfg)
+		if err != nil {
+			return nil, err
+		}
+		ls = append(ls, layout{pattern: p, parse: parse})
+	}
+	return ls, nil
+}
+
+func tokenize(line string, layouts []layout, cfg *config) []token {
+	var ts []token
+	for len(line) > 0 {
+		c, z := utf8.DecodeRuneInString(line)
+		var (
+			t token
+			n int
+		)
+		switch {
+		case isBlank(c):
+			n = inferSpan(line, isBlank)
+			t = token{kind: 'b', text: line[:n]}
+		case c == '[' && inferPID(line) > 0:
+			n = inferPID(line)
+			ts = append(ts, token{text: "["}, token{kind: 'p', text: line[1:n]})
+			line = line[n:]
+			n, t = 1, token{text: "]"}
+		case !isWordRune(c):
+			n = z
+			t = token{text: line[:n]}
+		default:
+			if t, n = inferTime(line, layouts); n > 0 {
+				break
+			}
+			if t, n = inferHost(line); n > 0 {
+				break
+			}
+			n = inferSpan(line, isWordRune)
+			t = token{kind: 'w', text: line[:n]}
+			if isLevel(t.text, cfg) {
+				t.kind = 'l'
+			}
+		}
+		ts = append(ts, t)
+		line = line[n:]
+	}
+	return ts
+}
+
+func inferTime(line string, layouts []layout) (token, int) {
+	var (
+		t token
+		n int
+	)
+	for _, y := range layouts {
+		size := inferLength(line, func(r *bytes.Reader) error {
+			var w when
+			return y.parse(&w, r)
+		})
+		if size == 0 || size < n || !isBoundary(line[size:]) {
+			continue
+		}
+		if size > n {
+			n, t = size, token{kind: 't', text: line[:size]}
+		}
+		t.args = append(t.args, y.pattern)
+	}
+	return t, n
+}
+
+func inferHost(line string) (token, int) {
+	var h host
+	n := inferLength(line, func(r *bytes.Reader) error { return parseIPv4(&h, r) })
+	if n > 0 {
+		format := "%4"
+		if strings.HasPrefix(line[n:], ":") {
+			if p := inferSpan(line[n+1:], isDigit); p > 0 && isBoundary(line[n+1+p:]) {
+				format, n = "%4:%p", n+1+p
+			}
+		}
+		if isBoundary(line[n:]) {
+			return token{kind: 'h', text: line[:n], args: []string{format}}, n
+		}
+	}
+	n = inferLength(line, func(r *bytes.Reader) error { return parseIPv6(&h, r) })
+	if n > 0 && isBoundary(line[n:]) {
+		if str := line[:n]; strings.Contains(str, "::") || strings.Count(str, ":") == ip6len-1 {
+			return token{kind: 'h', text: str, args: []string{"%6"}}, n
+		}
+	}
+	return token{}, 0
+}
+
+// inferPID returns the length of a pid enclosed in brackets at the start of
+// line or 0 if line does not start with such a pid.
+func inferPID(line string) int {
+	n := inferSpan(line[1:], isDigit)
+	if n == 0 || !strings.HasPrefix(line[n+1:], "]") {
+		return 0
+	}
+	return n + 1
+}
+
+func inferLength(line string, parse func(*bytes.Reader) error) int {
+	r := bytes.NewReader([]byte(line))
+	if err := parse(r); err != nil {
+		return 0
+	}
+	return len(line) - r.Len()
+}
+
+func inferSpan(line string, accept func(rune) bool) int {
+	if x := strings.IndexFunc(line, func(r rune) bool { return !accept(r) }); x >= 0 {
+		return x
+	}
+	return len(line)
+}
+
+func inferPattern(lines [][]token, cfg *config) string {
+	var (
+		cols = alignTokens(lines)
+		head = -1
+	)
+	for i, c := range cols {
+		if c.kind != 0 && c.kind != 'b' && c.kind != 'w' {
+			head = i
+		}
+	}
+	if head >= 0 {
+		for head < len(cols)-1 && cols[head+1].kind == 0 {
+			head++
+		}
+		for head < len(cols)-1 && cols[head+1].kind == 'b' {
+			head++
+		}
+	}
+	var (
+		buf     strings.Builder
+		process bool
+	)
+	for i := 0; i <= head; i++ {
+		c := cols[i]
+		switch c.kind {
+		case 0:
+			buf.WriteString(inferLiteral(c.texts[0]))
+		case 'b':
+			if sameTexts(c.texts) {
+				buf.WriteString(c.texts[0])
+			} else {
+				buf.WriteString("%b")
+			}
+		case 't', 'h':
+			fmt.Fprintf(&buf, "%%%c(%s)", c.kind, c.args[0])
+		case 'p', 'l':
+			fmt.Fprintf(&buf, "%%%c", c.kind)
+		case 'w':
+			next := cols[i+1]
+			switch {
+			case next.kind == 'b':
+				buf.WriteString("%w")
+			case !process && next.texts[0] == "[" && i+2 <= head && cols[i+2].kind == 'p':
+				process = true
+				buf.WriteString("%n")
+				if extra := inferClass(c.texts, cfg); extra != "" {
+					fmt.Fprintf(&buf, "([:name:]%s)", extra)
+				}
+			default:
+				fmt.Fprintf(&buf, "%%r([^%s\\s]+)", regexp.QuoteMeta(next.texts[0]))
+			}
+		}
+	}
+	for _, ts := range lines {
+		if len(ts) > head+1 {
+			buf.WriteString("%m")
+			break
+		}
+	}
+	return buf.String()
+}
+
+// alignTokens returns the columns of the tokens found with the same kind in all
+// the lines. It stops at the first position where the lines diverge.
+func alignTokens(lines [][]token) []column {
+	var cols []column
+	for i := 0; ; i++ {
+		var c column
+		for j, ts := range lines {
+			if i >= len(ts) {
+				return cols
+			}
+			t := ts[i]
+			switch {
+			case j == 0:
+				c.kind, c.args = t.kind, t.args
+			case t.kind != c.kind:
+				return cols
+			case t.kind == 0 && t.text != c.texts[0]:
+				return cols
+			case len(c.args) > 0:
+				if c.args = intersect(c.args, t.args); len(c.args) == 0 {
+					return cols
+				}
+			}
+			c.texts = append(c.texts, t.text)
+		}
+		cols = append(cols, c)
+	}
+}
+
+// inferClass returns the characters of texts that are not accepted by the
+// default class of names.
+func inferClass(texts []string, cfg *config) string {
+	var buf strings.Builder
+	for _, t := range texts {
+		for _, r := range t {
+			if cfg.alpha(r) || strings.ContainsRune(buf.String(), r) {
+				continue
+			}
+			if isEscape(r) {
+				buf.WriteRune('\\')
+			}
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+func inferLiteral(str string) string {
+	var buf strings.Builder
+	for _, r := range str {
+		switch {
+		case r == '%':
+			buf.WriteRune(r)
+		case isEscape(r):
+			buf.WriteRune('\\')
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func intersect(left, right []string) []string {
+	var list []string
+	for _, str := range left {
+		for _, other := range right {
+			if str == other {
+				list = append(list, str)
+				break
+			}
+		}
+	}
+	return list
+}
+
+func sameTexts(texts []string) bool {
+	for i := 1; i < len(texts); i++ {
+		if texts[i] != texts[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// isLevel reports whether str is one of the known levels. Single letters are
+// only considered as levels when they are upper case.
+func isLevel(str string, cfg *config) bool {
+	if _, ok := cfg.levels[strings.ToLower(str)]; !ok {
+		return false
+	}
+	r, z := utf8.DecodeRuneInString(str)
+	return z < len(str) || (r >= 'A' && r <= 'Z')
+}
+
+func isBoundary(line string) bool {
+	r, _ := utf8.DecodeRuneInString(line)
+	return line == "" || !isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	return !isBlank(r) && !isEOL(r) && !strings.ContainsRune("[](){}<>:,;=\"'|", r)
+}
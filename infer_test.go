@@ -0,0 +1,105 @@
+package log
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInfer(t *testing.T) {
+	data := []struct {
+		Name    string
+		Samples []string
+		Pattern string
+	}{
+		{
+			Name: "syslog",
+			Samples: []string{
+				"Mar  7 10:00:00 web sshd[42]: opened",
+				"Mar 17 11:00:00 web cron[7]: run",
+			},
+			Pattern: "%t(%b %e %H:%M:%S) %w %n[%p]: %m",
+		},
+		{
+			Name: "iso-level",
+			Samples: []string{
+				"2021-03-07T10:00:00Z INFO started",
+				"2021-03-07T10:00:01Z ERROR failed x",
+			},
+			Pattern: "%t(%y-%m-%dT%H:%M:%S%Z) %l %m",
+		},
+		{
+			Name: "apache",
+			Samples: []string{
+				`127.0.0.1 - - [07/Mar/2021:10:00:00 +0000] "GET / HTTP/1.1" 200 12`,
+			},
+			Pattern: "%h(%4) %w %w [%t(%d/%b/%y:%H:%M:%S %Z)] %m",
+		},
+		{
+			Name: "process-class",
+			Samples: []string{
+				"2021-03-07 10:00:00 /usr/bin/app[3]: x",
+				"2021-03-07 10:00:00 my.app[4]: y",
+			},
+			Pattern: "%t(%y-%m-%d %H:%M:%S) %n([:name:]/.)[%p]: %m",
+		},
+		{
+			Name: "ipv4-port",
+			Samples: []string{
+				"10.0.0.1:8080 up",
+				"10.0.0.2:80 down",
+			},
+			Pattern: "%h(%4:%p) %m",
+		},
+		{
+			Name: "ipv4-end-of-line",
+			Samples: []string{
+				"GET 10.0.0.1",
+				"POST 10.0.0.2",
+			},
+			Pattern: "%w %h(%4)",
+		},
+		{
+			Name:    "ipv6",
+			Samples: []string{"2001:db8::1 up"},
+			Pattern: "%h(%6) %m",
+		},
+		{
+			Name:    "hexadecimal-word",
+			Samples: []string{"cafe babe"},
+			Pattern: "%m",
+		},
+		{
+			Name: "half-day",
+			Samples: []string{
+				"3/7/2021 1:05:09 PM start",
+				"3/7/2021 11:05:09 AM stop",
+			},
+			Pattern: "%t(%-m/%-d/%y %-K:%M:%S %p) %m",
+		},
+		{
+			Name: "text",
+			Samples: []string{
+				"hello world",
+				"foo bar",
+			},
+			Pattern: "%m",
+		},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			got, err := Infer(d.Samples)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != d.Pattern {
+				t.Errorf("pattern mismatched! want %s, got %s", d.Pattern, got)
+			}
+		})
+	}
+}
+
+func TestInferError(t *testing.T) {
+	if _, err := Infer(nil); !errors.Is(err, ErrPattern) {
+		t.Errorf("expected ErrPattern, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	goPrefix       = "go:"
+	strftimePrefix = "strftime:"
+)
+
+// translateLayout rewrites a time layout given in the syntax of the Go time
+// package (go: prefix) or of strftime (strftime: prefix) into a time pattern.
+// Layouts without prefix are returned as is.
+func translateLayout(str string) (string, error) {
+	switch {
+	case strings.HasPrefix(str, goPrefix):
+		return translateGo(strings.TrimPrefix(str, goPrefix))
+	case strings.HasPrefix(str, strftimePrefix):
+		return translateStrftime(strings.TrimPrefix(str, strftimePrefix))
+	default:
+		return str, nil
+	}
+}
+
+// goElements maps the elements of the Go reference layout to time specifiers.
+// Longer elements come first so that they are preferred over their prefixes.
+// An empty specifier marks an element that can not be translated.
+var goElements = []struct {
+	std  string
+	spec string
+}{
+	{"January", "%B"},
+	{"Jan", "%b"},
+	{"Monday", "%A"},
+	{"Mon", "%a"},
+	{"MST", "%z"},
+	{"2006", "%y"},
+	{"002", "%j"},
+	{"__2", ""},
+	{"_2", "%e"},
+	{"01", "%m"},
+	{"02", "%d"},
+	{"03", "%K"},
+	{"04", "%M"},
+	{"05", "%S"},
+	{"06", ""},
+	{"15", "%H"},
+	{"1", "%-m"},
+	{"2", "%-d"},
+	{"3", "%-K"},
+	{"4", "%-M"},
+	{"5", "%-S"},
+	{"PM", "%p"},
+	{"pm", "%p"},
+	{"Z07:00:00", ""},
+	{"Z070000", ""},
+	{"Z07:00", "%Z"},
+	{"Z0700", "%Z"},
+	{"Z07", "%Z"},
+	{"-07:00:00", ""},
+	{"-070000", ""},
+	{"-07:00", "%Z"},
+	{"-0700", "%Z"},
+	{"-07", "%Z"},
+}
+
+func translateGo(layout string) (string, error) {
+	var buf strings.Builder
+Loop:
+	for len(layout) > 0 {
+		if n := goFraction(layout); n > 0 {
+			if layout[1] == '9' {
+				buf.WriteString("%F")
+			} else {
+				buf.WriteString(layout[:1] + "%f")
+			}
+			layout = layout[n:]
+			continue
+		}
+		for _, e := range goElements {
+			if !strings.HasPrefix(layout, e.std) {
+				continue
+			}
+			if e.spec == "" {
+				return "", fmt.Errorf("%w(time): unsupported go layout element %s", ErrSyntax, e.std)
+			}
+			buf.WriteString(e.spec)
+			layout = layout[len(e.std):]
+			continue Loop
+		}
+		if layout[0] == '%' {
+			buf.WriteByte('%')
+		}
+		buf.WriteByte(layout[0])
+		layout = layout[1:]
+	}
+	return buf.String(), nil
+}
+
+// goFraction returns the length of the fractional second (eg: .000 or ,999)
+// at the start of layout or 0 if layout does not start with one. As in Go, a
+// fraction made of 9 is optional.
+func goFraction(layout string) int {
+	if len(layout) < 2 || (layout[0] != '.' && layout[0] != ',') {
+		return 0
+	}
+	if layout[1] != '0' && layout[1] != '9' {
+		return 0
+	}
+	n := 2
+	for n < len(layout) && layout[n] == layout[1] {
+		n++
+	}
+	if n < len(layout) && isDigit(rune(layout[n])) {
+		return 0
+	}
+	return n
+}
+
+// strftimeElements maps the conversions of strftime to time specifiers. The
+// conversions missing from the table can not be translated.
+var strftimeElements = map[byte]string{
+	'Y': "%y",
+	'm': "%m",
+	'd': "%d",
+	'e': "%e",
+	'j': "%j",
+	'H': "%H",
+	'I': "%K",
+	'M': "%M",
+	'S': "%S",
+	'f': "%f",
+	'p': "%p",
+	'P': "%p",
+	'a': "%a",
+	'A': "%A",
+	'b': "%b",
+	'h': "%b",
+	'B': "%B",
+	'z': "%Z",
+	'Z': "%z",
+	's': "%s",
+	'F': "%y-%m-%d",
+	'T': "%H:%M:%S",
+	'R': "%H:%M",
+	't': "\t",
+	'%': "%%",
+}
+
+func translateStrftime(layout string) (string, error) {
+	var buf strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			buf.WriteByte(layout[i])
+			continue
+		}
+		i++
+		if i >= len(layout) {
+			return "", fmt.Errorf("%w(time): incomplete strftime conversion", ErrSyntax)
+		}
+		unpadded := layout[i] == '-'
+		if unpadded {
+			if i++; i >= len(layout) {
+				return "", fmt.Errorf("%w(time): incomplete strftime conversion", ErrSyntax)
+			}
+		}
+		spec, ok := strftimeElements[layout[i]]
+		if !ok {
+			return "", fmt.Errorf("%w(time): unsupported strftime conversion %%%c", ErrSyntax, layout[i])
+		}
+		if unpadded {
+			if _, n := whenField(rune(spec[len(spec)-1])); len(spec) != 2 || n == 0 {
+				return "", fmt.Errorf("%w(time): unsupported strftime conversion %%-%c", ErrSyntax, layout[i])
+			}
+			spec = "%-" + spec[1:]
+		}
+		buf.WriteString(spec)
+	}
+	return buf.String(), nil
+}
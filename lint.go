@@ -0,0 +1,128 @@
+package log
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Warning is a construct of a pattern reported by Lint. Pos is the offset of
+// the construct in the source of the pattern.
+type Warning struct {
+	Pos int
+	Msg string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%d: %s", w.Pos, w.Msg)
+}
+
+// Lint walks a compiled pattern and reports the constructs that are ambiguous
+// or that make the rest of the pattern unreachable.
+func Lint(p *Pattern) []Warning {
+	var ws []Warning
+	lintSegments(p.segs, nil, p.cfg, &ws)
+	return ws
+}
+
+func lintSegments(segs []segment, follow *segment, cfg *config, ws *[]Warning) {
+	report := func(pos int, msg string, args ...interface{}) {
+		*ws = append(*ws, Warning{Pos: pos, Msg: fmt.Sprintf(msg, args...)})
+	}
+	for i, s := range segs {
+		next := follow
+		if i < len(segs)-1 {
+			next = &segs[i+1]
+		}
+		switch s.spec {
+		case '@':
+			for j, a := range s.alts {
+				if len(a) == 0 && j < len(s.alts)-1 {
+					report(s.pos, "empty branch %s always matches, next branches are unreachable", s.names[j])
+				}
+				for k := 0; k < j; k++ {
+					if sameSegments(a, s.alts[k]) {
+						report(s.pos, "branch %s is unreachable, same as branch %s", s.names[j], s.names[k])
+						break
+					}
+				}
+				lintSegments(a, next, cfg, ws)
+			}
+			continue
+		case 'm':
+			if next != nil {
+				report(s.pos, "%%m consumes the rest of the line, %s is unreachable", describeSegment(*next))
+			}
+			continue
+		case '*':
+			if next == nil {
+				report(s.pos, "%%* at the end of the pattern discards nothing")
+			} else if next.spec != 0 {
+				report(s.pos, "%%* is not followed by a literal but by %s", describeSegment(*next))
+			}
+			continue
+		}
+		if next == nil {
+			continue
+		}
+		var accept func(rune) bool
+		switch s.spec {
+		case 'n', 'u', 'g':
+			accept, _ = cfg.class(s.arg)
+		case 'p':
+			accept = isDigit
+		case 'l':
+			if s.arg == "" {
+				accept = cfg.letter
+			}
+		case 'w':
+			accept = func(r rune) bool { return !isBlank(r) && !isEOL(r) }
+		}
+		if accept == nil {
+			continue
+		}
+		if next.spec == 0 {
+			if r, _ := utf8.DecodeRuneInString(next.arg); accept(r) {
+				report(s.pos, "%%%c swallows the first character of literal %q", s.spec, next.arg)
+			}
+			continue
+		}
+		switch next.spec {
+		case 'n', 'u', 'g', 'p', 'l', 'w', 'h', 'c', 't':
+			report(s.pos, "%%%c is directly followed by %s without separator", s.spec, describeSegment(*next))
+		}
+	}
+}
+
+func describeSegment(s segment) string {
+	switch s.spec {
+	case 0:
+		return fmt.Sprintf("literal %q", s.arg)
+	case '@':
+		return "alternatives"
+	default:
+		return fmt.Sprintf("%%%c", s.spec)
+	}
+}
+
+func sameSegments(a, b []segment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].spec != b[i].spec || a[i].arg != b[i].arg {
+			return false
+		}
+		if a[i].spec != '@' {
+			continue
+		}
+		if len(a[i].alts) != len(b[i].alts) {
+			return false
+		}
+		for j := range a[i].alts {
+			if !sameSegments(a[i].alts[j], b[i].alts[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package log
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	data := []struct {
+		Pattern string
+		Want    []Warning
+	}{
+		{
+			Pattern: "%n[%p]: %m",
+		},
+		{
+			Pattern: "%m %n",
+			Want: []Warning{
+				{Pos: 0, Msg: `%m consumes the rest of the line, literal " " is unreachable`},
+			},
+		},
+		{
+			Pattern: "%n-%p",
+			Want: []Warning{
+				{Pos: 0, Msg: `%n swallows the first character of literal "-"`},
+			},
+		},
+		{
+			Pattern: "%w%p",
+			Want: []Warning{
+				{Pos: 0, Msg: "%w is directly followed by %p without separator"},
+			},
+		},
+		{
+			Pattern: "%*%m",
+			Want: []Warning{
+				{Pos: 0, Msg: "%* is not followed by a literal but by %m"},
+			},
+		},
+		{
+			Pattern: "@(?<a>%n|?<b>%n): %m",
+			Want: []Warning{
+				{Pos: 0, Msg: "branch b is unreachable, same as branch a"},
+			},
+		},
+		{
+			Pattern: "@(|%n): %m",
+			Want: []Warning{
+				{Pos: 0, Msg: "empty branch 0 always matches, next branches are unreachable"},
+			},
+		},
+	}
+	for _, d := range data {
+		got := Lint(MustCompile(d.Pattern))
+		if !reflect.DeepEqual(got, d.Want) {
+			t.Errorf("%s: warnings mismatched!\nwant: %v\ngot:  %v", d.Pattern, d.Want, got)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WithLocale sets the language of the day and month names parsed by the time
+// specifiers. Supported languages are en (default), fr, de and es.
+func WithLocale(lang string) Option {
+	return func(c *config) error {
+		loc, ok := locales[strings.ToLower(lang)]
+		if !ok {
+			return fmt.Errorf("%w: unsupported locale %s", ErrSyntax, lang)
+		}
+		c.locale = loc
+		return nil
+	}
+}
+
+// locale holds the names of the days (starting on monday) and of the months
+// in a language. A name can have several spellings separated by |. When dotted
+// is set, the abbreviations can be followed by a dot.
+type locale struct {
+	days        []string
+	shortDays   []string
+	months      []string
+	shortMonths []string
+	dotted      bool
+}
+
+var locales = map[string]*locale{
+	"en": {
+		days:        []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"},
+		shortDays:   []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"},
+		months:      []string{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"},
+		shortMonths: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"},
+	},
+	"fr": {
+		days:        []string{"lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi", "dimanche"},
+		shortDays:   []string{"lun", "mar", "mer", "jeu", "ven", "sam", "dim"},
+		months:      []string{"janvier", "février|fevrier", "mars", "avril", "mai", "juin", "juillet", "août|aout", "septembre", "octobre", "novembre", "décembre|decembre"},
+		shortMonths: []string{"janv|jan", "févr|fevr|fév|fev", "mars|mar", "avr", "mai", "juin", "juil", "août|aout|aoû", "sept|sep", "oct", "nov", "déc|dec"},
+		dotted:      true,
+	},
+	"de": {
+		days:        []string{"montag", "dienstag", "mittwoch", "donnerstag", "freitag", "samstag|sonnabend", "sonntag"},
+		shortDays:   []string{"mo|mon", "di|die", "mi|mit", "do|don", "fr|fre", "sa|sam", "so|son"},
+		months:      []string{"januar", "februar", "märz|maerz", "april", "mai", "juni", "juli", "august", "september", "oktober", "november", "dezember"},
+		shortMonths: []string{"jan", "feb", "märz|mär|mrz", "apr", "mai", "juni|jun", "juli|jul", "aug", "sept|sep", "okt", "nov", "dez"},
+		dotted:      true,
+	},
+	"es": {
+		days:        []string{"lunes", "martes", "miércoles|miercoles", "jueves", "viernes", "sábado|sabado", "domingo"},
+		shortDays:   []string{"lun", "mar", "mié|mie", "jue", "vie", "sáb|sab", "dom"},
+		months:      []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre|setiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: []string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept|sep|set", "oct", "nov", "dic"},
+		dotted:      true,
+	},
+}
+
+func parseDayName(cfg *config, full bool) whenfunc {
+	names := cfg.locale.shortDays
+	if full {
+		names = cfg.locale.days
+	}
+	dotted := cfg.locale.dotted && !full
+	return func(w *when, r *bytes.Reader) error {
+		x, err := parseName(r, names, dotted, cfg.letter)
+		if err == nil {
+			w.WeekDay = x + 1
+		}
+		return err
+	}
+}
+
+func parseMonthName(cfg *config, full bool) whenfunc {
+	names := cfg.locale.shortMonths
+	if full {
+		names = cfg.locale.months
+	}
+	dotted := cfg.locale.dotted && !full
+	return func(w *when, r *bytes.Reader) error {
+		x, err := parseName(r, names, dotted, cfg.letter)
+		if err == nil {
+			w.Mon = x + 1
+		}
+		return err
+	}
+}
+
+// parseName returns the index of the longest name matching case insensitively
+// at the current position of r. The name can not be followed by a letter.
+func parseName(r *bytes.Reader, names []string, dotted bool, letter func(rune) bool) (int, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		index = -1
+		size  int64
+	)
+	for i, name := range names {
+		for _, n := range strings.Split(name, "|") {
+			r.Seek(start, io.SeekStart)
+			if !matchName(r, n, letter) {
+				continue
+			}
+			if z := r.Size() - int64(r.Len()) - start; z > size {
+				index, size = i, z
+			}
+		}
+	}
+	r.Seek(start+size, io.SeekStart)
+	if index < 0 {
+		return 0, ErrPattern
+	}
+	if dotted && peek(r) == '.' {
+		r.ReadRune()
+	}
+	return index, nil
+}
+
+func matchName(r *bytes.Reader, name string, letter func(rune) bool) bool {
+	for _, n := range name {
+		g, _, _ := r.ReadRune()
+		if !equalFold(n, g) {
+			return false
+		}
+	}
+	return !letter(peek(r))
+}
@@ -6,10 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // line specifiers (writing)
@@ -21,57 +26,82 @@ import (
 // %h: host
 // %l: level
 // %m: message
+// %c: source location (file:line)
+// %f: source file
+// %L: source line
 // %#: line
 // %[digit]: word
 // %%: a percent sign
 // c : any character(s)
 
 // line specifiers (read)
-// %t: time (time format, eg, %y-%m-%d)
-// %n: process
+// %t: time (time format, eg, %y-%m-%d, or go:2006-01-02, strftime:%Y-%m-%d)
+// %n: process (optional class of accepted characters, eg, [:alnum:]-./)
 // %p: pid
-// %u: user
-// %g: group
+// %u: user (optional class of accepted characters)
+// %g: group (optional class of accepted characters)
 // %h: host (host format, eg, ip:port, fqdn)
 // %l: level (list of accepted level)
+// %P: syslog priority (<pri>, facility stored as field, severity as level)
+// %c: source location (file:line, a :column suffix is left unconsumed)
 // %m: message
 // %w: word
+// %r: regular expression (named groups are stored as fields, whole match as word otherwise)
 // %b: blank
-// %*: discard one or multiple characters
+// %*: discard as few characters as possible for the rest of the pattern to match
+// %{name}: macro, the named sub-pattern is expanded in place
 // %%: a percent sign
+// @(...|...): alternatives, a branch can be labeled with ?<label>
 // c : any character(s)
 
 // host specifiers
 // %p: port
 // %4: ipv4
 // %6: ipv6 // rfc5952
-// %h: hostname
+// %h: hostname (optional class of accepted characters)
 // %f: fqdn
 // %m: net mask
 // %F: ipv4:port
 // %S: ipv6:port
 // %Q: fqdn:port
 
+// character classes
+// [:alpha:]: letters
+// [:digit:]: digits
+// [:alnum:]: letters and digits
+// [:punct:]: punctuation and symbols
+// [:name:]: letters, digits, - and _ (default)
+// c : any other character is accepted as is
+// the argument of %n, %u, %g and %h(%h) is only read as a class when it starts
+// with a named class, eg, %n([:name:]./), otherwise the ( is a literal
+
 // time specifiers
 // %y: year (4 digits)
 // %m: month (2 digits)
 // %b: month name (abbr)
+// %B: month name
 // %a: day name (abbr)
+// %A: day name
 // %d: day (2 digits)
+// %e: day (2 digits, padded with a blank)
 // %j: day of year (3 digits)
 // %H: hour of day (2 digits)
+// %K: hour of half day (2 digits)
+// %p: AM/PM
 // %M: minute of hour (2 digits)
 // %S: second of minute (2 digits)
 // %f: fraction of second (up to 9 digits)
-// %s: unix timestamp
-// %z: zone
+// %F: optional fraction of second preceded by . or , (eg, .123)
+// %s: unix timestamp (seconds with optional decimal fraction)
+// %L: unix timestamp in milliseconds
+// %U: unix timestamp in microseconds
+// %N: unix timestamp in nanoseconds
+// %r: seconds (with optional decimal fraction) elapsed since an anchor (eg, boot time), leading blanks are skipped
+// %Z: zone (Z or offset, eg +02:00)
+// %z: zone name (abbreviation or IANA area/location name, eg CET, Europe/Brussels)
 // %I: %y-%m-%d %H:%M:%S%Z
 // %R: %y-%m-%dT%H:%M:%S%Z
-
-func init() {
-	sort.Strings(days)
-	sort.Strings(months)
-}
+// %-[dmjHKMS]: same as the specifier without the padding (1 or more digits)
 
 var (
 	ErrPattern = errors.New("invalid pattern")
@@ -81,41 +111,373 @@ var (
 type Entry struct {
 	Line string `json:"-"`
 
-	Pid     int       `json:"pid"`
-	Process string    `json:"process"`
-	User    string    `json:"user"`
-	Group   string    `json:"group"`
-	Level   string    `json:"level"`
-	Message string    `json:"message"`
-	Words   []string  `json:"words"`
-	Host    string    `json:"host"`
-	When    time.Time `json:"when"`
+	Pid     int               `json:"pid"`
+	Process string            `json:"process"`
+	User    string            `json:"user"`
+	Group   string            `json:"group"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Words   []string          `json:"words"`
+	Host    string            `json:"host"`
+	Fields  map[string]string `json:"fields"`
+	When    time.Time         `json:"when"`
+
+	Source     string `json:"source"`
+	SourceLine int    `json:"sourceline"`
+
+	Pattern  string   `json:"pattern"`
+	Branches []string `json:"branches"`
+
+	// timestamp parsed without year, kept to build When again once the year
+	// is inferred
+	stamp *when
+}
+
+// Option configures how a pattern is compiled and how a Reader uses it.
+type Option func(*config) error
+
+// WithLevels adds aliases to the table used to normalize levels. Keys are
+// matched case insensitively and map to the canonical name stored in
+// Entry.Level. Values are themselves normalized with the built-in aliases.
+func WithLevels(aliases map[string]string) Option {
+	return func(c *config) error {
+		for k, v := range aliases {
+			if k == "" || v == "" {
+				return fmt.Errorf("%w: empty level alias", ErrSyntax)
+			}
+			if a, ok := levelAliases[strings.ToLower(v)]; ok {
+				v = a
+			}
+			c.levels[strings.ToLower(k)] = strings.ToUpper(v)
+		}
+		return nil
+	}
+}
+
+// WithASCII restricts the letters accepted in names, users, groups, hostnames
+// and levels to ASCII letters instead of the letters of the Unicode categories.
+func WithASCII() Option {
+	return func(c *config) error {
+		c.ascii = true
+		c.letter = isLetter
+		c.digit = isDigit
+		c.alpha = isAlpha
+		return nil
+	}
+}
+
+// Mode controls how a pattern is matched against a line. By default, a pattern
+// has to match from the first byte of a line and the trailing characters not
+// consumed by the pattern are ignored.
+type Mode int
+
+const (
+	// MatchStrict requires the whole line to be consumed by the pattern.
+	MatchStrict Mode = 1 << iota
+	// MatchSearch lets the pattern start at any offset of the line.
+	MatchSearch
+)
+
+// WithMode sets the mode used to match lines.
+func WithMode(mode Mode) Option {
+	return func(c *config) error {
+		c.mode = mode
+		return nil
+	}
+}
+
+// WithFoldCase makes the literals of a pattern match case insensitively.
+func WithFoldCase() Option {
+	return func(c *config) error {
+		c.fold = true
+		return nil
+	}
+}
+
+// WithLooseBlanks makes any run of blanks in the literals of a pattern match
+// one or more blanks in the line.
+func WithLooseBlanks() Option {
+	return func(c *config) error {
+		c.blanks = true
+		return nil
+	}
+}
+
+// WithLocation sets the location of the timestamps parsed without zone. They
+// are in UTC by default.
+func WithLocation(loc *time.Location) Option {
+	return func(c *config) error {
+		if loc == nil {
+			return fmt.Errorf("%w: nil location", ErrSyntax)
+		}
+		c.location = loc
+		return nil
+	}
+}
+
+// WithYear sets the reference time (eg: modification time of a file) used to
+// infer the year of the timestamps parsed without year. They get the year of
+// ref, or the previous one if they would be later than ref. A Reader also
+// increments the year when a timestamp goes back by more than half a year from
+// the previous one (eg: from December to January).
+func WithYear(ref time.Time) Option {
+	return func(c *config) error {
+		c.reference = ref
+		return nil
+	}
+}
+
+// WithAnchor sets the time (eg: boot time of a system) to which the relative
+// timestamps are added.
+func WithAnchor(anchor time.Time) Option {
+	return func(c *config) error {
+		c.anchor = anchor
+		return nil
+	}
+}
+
+// WithLenientTime disables the validation of the timestamps. Out of range
+// components are normalized (eg: 32 january becomes 1 february) and the day of
+// week is not checked against the date.
+func WithLenientTime() Option {
+	return func(c *config) error {
+		c.lenient = true
+		return nil
+	}
+}
+
+// WithMacros defines named sub-patterns that can be referenced with %{name}
+// inside a pattern.
+func WithMacros(macros map[string]string) Option {
+	return func(c *config) error {
+		for k, v := range macros {
+			if k == "" {
+				return fmt.Errorf("%w(macro): empty name", ErrSyntax)
+			}
+			c.macros[k] = v
+		}
+		return nil
+	}
+}
+
+type config struct {
+	levels map[string]string
+	macros map[string]string
+	// names of the macros being expanded while compiling a pattern
+	expanding []string
+
+	mode   Mode
+	fold   bool
+	blanks bool
+
+	location  *time.Location
+	reference time.Time
+	anchor    time.Time
+	locale    *locale
+	lenient   bool
+
+	ascii  bool
+	letter func(rune) bool
+	digit  func(rune) bool
+	alpha  func(rune) bool
+}
+
+func makeConfig(options []Option) (*config, error) {
+	c := config{
+		levels: make(map[string]string),
+		macros: make(map[string]string),
+		letter: isUnicodeLetter,
+		digit:  unicode.IsDigit,
+		alpha:  isUnicodeAlpha,
+		locale: locales["en"],
+	}
+	for k, v := range levelAliases {
+		c.levels[k] = v
+	}
+	for _, o := range options {
+		if err := o(&c); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// class returns the function accepting the characters described by str. The
+// default class is used when str does not name any class.
+func (c *config) class(str string) (func(rune) bool, error) {
+	if str == "" {
+		return c.alpha, nil
+	}
+	var (
+		accepts []func(rune) bool
+		extra   []rune
+	)
+	for len(str) > 0 {
+		if strings.HasPrefix(str, "[:") {
+			if x := strings.Index(str, ":]"); x > 0 {
+				var fn func(rune) bool
+				switch name := str[2:x]; name {
+				case "alpha":
+					fn = c.letter
+				case "digit":
+					fn = c.digit
+				case "alnum":
+					fn = func(r rune) bool { return c.letter(r) || c.digit(r) }
+				case "punct":
+					fn = func(r rune) bool {
+						if c.ascii && r >= utf8.RuneSelf {
+							return false
+						}
+						return unicode.IsPunct(r) || unicode.IsSymbol(r)
+					}
+				case "name":
+					fn = c.alpha
+				default:
+					return nil, fmt.Errorf("%w: unknown character class %s", ErrSyntax, name)
+				}
+				accepts = append(accepts, fn)
+				str = str[x+2:]
+				continue
+			}
+		}
+		r, z := utf8.DecodeRuneInString(str)
+		extra = append(extra, r)
+		str = str[z:]
+	}
+	if len(accepts) == 0 {
+		accepts = append(accepts, c.alpha)
+	}
+	fn := func(r rune) bool {
+		for _, a := range accepts {
+			if a(r) {
+				return true
+			}
+		}
+		for _, x := range extra {
+			if x == r {
+				return true
+			}
+		}
+		return false
+	}
+	return fn, nil
+}
+
+// level returns the canonical name of a level. Levels without alias are
+// only upper cased.
+func (c *config) level(str string) string {
+	if str == "" {
+		return str
+	}
+	if v, ok := c.levels[strings.ToLower(str)]; ok {
+		return v
+	}
+	return strings.ToUpper(str)
+}
+
+// snapshot returns a copy of e that restore uses to undo the changes made to e
+// by a parsing attempt that failed.
+func (e *Entry) snapshot() Entry {
+	s := *e
+	s.Fields = copyFields(e.Fields)
+	return s
+}
+
+func (e *Entry) restore(s Entry) {
+	*e = s
+	e.Fields = copyFields(s.Fields)
+}
+
+func copyFields(fields map[string]string) map[string]string {
+	if fields == nil {
+		return nil
+	}
+	c := make(map[string]string, len(fields))
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
 }
 
 type Reader struct {
 	inner *bufio.Scanner
 	err   error
 
-	keep  filterfunc
-	parse parsefunc
+	keep     filterfunc
+	patterns []*Pattern
+	names    []string
+
+	// reference time, year given to the timestamps without year and last
+	// timestamp it was given to
+	reference time.Time
+	year      int
+	last      time.Time
 }
 
-func NewReader(rs io.Reader, pattern, filter string) (*Reader, error) {
+func NewReader(rs io.Reader, pattern, filter string, options ...Option) (*Reader, error) {
+	return NewMultiReader(rs, []NamedPattern{{Pattern: pattern}}, filter, options...)
+}
+
+// NamedPattern is a read pattern given to NewMultiReader. Name is copied into
+// the Pattern field of the entries matched by the pattern.
+type NamedPattern struct {
+	Name    string
+	Pattern string
+}
+
+// NewMultiReader returns a Reader that tries each pattern in order on every
+// line and keeps the entry of the first one that matches.
+func NewMultiReader(rs io.Reader, patterns []NamedPattern, filter string, options ...Option) (*Reader, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("%w: no pattern given", ErrSyntax)
+	}
 	var (
 		r   Reader
 		err error
 	)
 	r.inner = bufio.NewScanner(rs)
 
-	if r.parse, err = parsePattern(pattern); err != nil {
-		return nil, err
+	for _, n := range patterns {
+		p, err := Compile(n.Pattern, options...)
+		if err != nil {
+			if n.Name != "" {
+				err = fmt.Errorf("%s: %w", n.Name, err)
+			}
+			return nil, err
+		}
+		r.patterns = append(r.patterns, p)
+		r.names = append(r.names, n.Name)
 	}
+	r.reference = r.patterns[0].cfg.reference
 	if r.keep, err = parseFilter(filter); err != nil {
 		return nil, err
 	}
 	return &r, nil
 }
 
+// NewPatternReader returns a Reader matching the lines of rs with a pattern
+// already compiled.
+func NewPatternReader(rs io.Reader, p *Pattern, filter string) (*Reader, error) {
+	keep, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	r := Reader{
+		inner:     bufio.NewScanner(rs),
+		keep:      keep,
+		patterns:  []*Pattern{p},
+		names:     []string{""},
+		reference: p.cfg.reference,
+	}
+	return &r, nil
+}
+
+// SetYear replaces the reference time given with WithYear to the patterns of
+// the Reader. It should be called before the first line is read.
+func (r *Reader) SetYear(ref time.Time) {
+	r.reference = ref
+}
+
 func (r *Reader) ReadAll() ([]Entry, error) {
 	var (
 		es  []Entry
@@ -149,7 +511,7 @@ func (r *Reader) Read() (Entry, error) {
 		if len(line) == 0 {
 			continue
 		}
-		err := r.parse(&e, bytes.NewReader(line))
+		x, err := r.match(line)
 		if err != nil {
 			if errors.Is(err, ErrPattern) {
 				continue
@@ -157,14 +519,253 @@ func (r *Reader) Read() (Entry, error) {
 			r.err = err
 			return e, r.err
 		}
-		if r.keep == nil || r.keep(e) {
-			e.Line = r.inner.Text()
+		if err := r.inferYear(&x); err != nil {
+			continue
+		}
+		if r.keep == nil || r.keep(x) {
+			e = x
 			break
 		}
 	}
 	return e, r.err
 }
 
+func (r *Reader) inferYear(e *Entry) error {
+	if e.stamp == nil || r.reference.IsZero() {
+		return nil
+	}
+	year := r.year
+	if year == 0 {
+		year = referenceYear(*e.stamp, r.reference)
+	}
+	if when, _ := e.stamp.inYear(year, true); !r.last.IsZero() && when.Before(r.last.AddDate(0, -6, 0)) {
+		year++
+	}
+	when, err := e.stamp.inYear(year, r.patterns[0].cfg.lenient)
+	if err != nil {
+		return err
+	}
+	r.year, r.last, e.When = year, when, when
+	return nil
+}
+
+func (r *Reader) match(line []byte) (Entry, error) {
+	var (
+		e   Entry
+		err error
+	)
+	for i, p := range r.patterns {
+		e, err = p.match(line)
+		if err == nil {
+			e.Pattern = r.names[i]
+			break
+		}
+		if !errors.Is(err, ErrPattern) {
+			break
+		}
+	}
+	return e, err
+}
+
+// Pattern is the compiled form of a read pattern. A Pattern is safe for
+// concurrent use by multiple goroutines.
+type Pattern struct {
+	expr  string
+	cfg   *config
+	segs  []segment
+	parse parsefunc
+}
+
+// Compile parses a read pattern and returns, if successful, a Pattern that can
+// be used to match lines against.
+func Compile(pattern string, options ...Option) (*Pattern, error) {
+	cfg, err := makeConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	segs, err := parsePattern(pattern, cfg)
+	if err != nil {
+		return nil, err
+	}
+	p := Pattern{
+		expr: pattern,
+		cfg:  cfg,
+		segs: segs,
+	}
+	if cfg.mode&MatchStrict != 0 {
+		end := segment{
+			pos:   len(pattern),
+			parse: parseEnd,
+		}
+		p.parse = mergeParse(append(segs[:len(segs):len(segs)], end))
+	} else {
+		p.parse = mergeParse(segs)
+	}
+	return &p, nil
+}
+
+// MustCompile is like Compile but panics if the pattern can not be parsed.
+func MustCompile(pattern string, options ...Option) *Pattern {
+	p, err := Compile(pattern, options...)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source text used to compile the pattern.
+func (p *Pattern) String() string {
+	return p.expr
+}
+
+// Match parses line and returns the Entry filled by the pattern. It returns
+// ErrPattern if line does not match the pattern.
+func (p *Pattern) Match(line []byte) (Entry, error) {
+	e, err := p.match(line)
+	if err != nil {
+		return e, err
+	}
+	if ref := p.cfg.reference; e.stamp != nil && !ref.IsZero() {
+		if e.When, err = e.stamp.inYear(referenceYear(*e.stamp, ref), p.cfg.lenient); err != nil {
+			return Entry{}, err
+		}
+	}
+	return e, nil
+}
+
+// match is like Match but leaves the timestamps without year as parsed. The
+// Reader infers their year from the previous lines.
+func (p *Pattern) match(line []byte) (Entry, error) {
+	var (
+		e   Entry
+		err error
+	)
+	for i := 0; i <= len(line); {
+		e = Entry{}
+		err = p.parse(&e, bytes.NewReader(line[i:]))
+		if errors.Is(err, io.EOF) {
+			err = ErrPattern
+		}
+		if err == nil || !errors.Is(err, ErrPattern) || p.cfg.mode&MatchSearch == 0 {
+			break
+		}
+		_, z := utf8.DecodeRune(line[i:])
+		if z == 0 {
+			break
+		}
+		i += z
+	}
+	if err != nil {
+		return e, err
+	}
+	e.Line = string(line)
+	return e, nil
+}
+
+// MatchString is like Match but line is given as a string.
+func (p *Pattern) MatchString(line string) (Entry, error) {
+	return p.Match([]byte(line))
+}
+
+// Schema describes the fields of an Entry that are filled by a Pattern.
+// Fields set only by some branches of an alternative are reported too.
+type Schema struct {
+	Time    bool     `json:"time"`
+	Layout  string   `json:"layout,omitempty"`
+	Host    bool     `json:"host"`
+	Address string   `json:"address,omitempty"`
+	Pid     bool     `json:"pid"`
+	Process bool     `json:"process"`
+	User    bool     `json:"user"`
+	Group   bool     `json:"group"`
+	Level   bool     `json:"level"`
+	Levels  []string `json:"levels,omitempty"`
+	Message bool     `json:"message"`
+	Source  bool     `json:"source"`
+	Words   int      `json:"words"`
+	Fields  []string `json:"fields,omitempty"`
+}
+
+// Schema walks the compiled pattern and returns the description of the
+// entries it produces.
+func (p *Pattern) Schema() Schema {
+	var s Schema
+	s.Words = describe(p.segs, &s, p.cfg)
+	sort.Strings(s.Levels)
+	sort.Strings(s.Fields)
+	return s
+}
+
+func describe(segs []segment, s *Schema, cfg *config) int {
+	var words int
+	for _, g := range segs {
+		switch g.spec {
+		case 't':
+			s.Time = true
+			if s.Layout == "" {
+				s.Layout = g.arg
+			}
+		case 'h':
+			s.Host = true
+			if s.Address == "" {
+				s.Address = g.arg
+			}
+		case 'p':
+			s.Pid = true
+		case 'n':
+			s.Process = true
+		case 'u':
+			s.User = true
+		case 'g':
+			s.Group = true
+		case 'l':
+			s.Level = true
+			s.Levels = appendUnique(s.Levels, splitLevels(g.arg, cfg)...)
+		case 'P':
+			s.Level = true
+			for _, v := range severities {
+				s.Levels = appendUnique(s.Levels, cfg.level(v))
+			}
+			s.Fields = appendUnique(s.Fields, fieldFacility)
+		case 'm':
+			s.Message = true
+		case 'c':
+			s.Source = true
+		case 'w':
+			words++
+		case 'r':
+			if len(g.names) == 0 {
+				words++
+			}
+			s.Fields = appendUnique(s.Fields, g.names...)
+		case '@':
+			var most int
+			for _, a := range g.alts {
+				if n := describe(a, s, cfg); n > most {
+					most = n
+				}
+			}
+			words += most
+		}
+	}
+	return words
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		var found bool
+		for _, x := range list {
+			if found = x == v; found {
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 type Writer struct {
 	inner  io.Writer
 	buffer bytes.Buffer
@@ -240,6 +841,12 @@ func parsePrint(pattern string) (printfunc, error) {
 				pfs = append(pfs, printLevel)
 			case 'm':
 				pfs = append(pfs, printMessage)
+			case 'c':
+				pfs = append(pfs, printSource)
+			case 'f':
+				pfs = append(pfs, printSourceFile)
+			case 'L':
+				pfs = append(pfs, printSourceLine)
 			case '#':
 				pfs = append(pfs, printLine)
 			default:
@@ -324,6 +931,26 @@ func printMessage(e Entry, w io.StringWriter) {
 	printString(e.Message, w)
 }
 
+func printSource(e Entry, w io.StringWriter) {
+	var str string
+	if e.Source != "" {
+		str = fmt.Sprintf("%s:%d", e.Source, e.SourceLine)
+	}
+	printString(str, w)
+}
+
+func printSourceFile(e Entry, w io.StringWriter) {
+	printString(e.Source, w)
+}
+
+func printSourceLine(e Entry, w io.StringWriter) {
+	var str string
+	if e.SourceLine > 0 {
+		str = strconv.Itoa(e.SourceLine)
+	}
+	printString(str, w)
+}
+
 func printLine(e Entry, w io.StringWriter) {
 	printString(e.Line, w)
 }
@@ -342,7 +969,19 @@ func parseFilter(str string) (filterfunc, error) {
 	return nil, nil
 }
 
-func parsePattern(pattern string) (parsefunc, error) {
+// segment is an element of a compiled read pattern. It keeps the specifier and
+// its argument next to the function doing the actual parsing so that a compiled
+// pattern can be inspected afterwards.
+type segment struct {
+	pos   int
+	spec  rune // 0 for literal, @ for alternatives
+	arg   string
+	alts  [][]segment
+	names []string // labels of the alternatives or named groups of %r
+	parse parsefunc
+}
+
+func parsePattern(pattern string, cfg *config) ([]segment, error) {
 	if pattern == "" {
 		return nil, fmt.Errorf("%w: empty pattern not allowed", ErrSyntax)
 	}
@@ -350,17 +989,34 @@ func parsePattern(pattern string) (parsefunc, error) {
 		until = func(r rune) bool { return r == 0 }
 		str   = bytes.NewReader([]byte(pattern))
 	)
-	_, fn, err := parsePatternUntil(str, until)
-	return fn, err
+	_, segs, err := parsePatternUntil(str, until, cfg)
+	return segs, err
 }
 
-func parsePatternUntil(str *bytes.Reader, until func(rune) bool) (rune, parsefunc, error) {
+func parsePatternUntil(str *bytes.Reader, until func(rune) bool, cfg *config) (rune, []segment, error) {
 	var (
-		pfs  []parsefunc
+		segs []segment
 		buf  bytes.Buffer
 		last rune
+		pos  int
 	)
+	literal := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		seg := segment{
+			pos:   pos,
+			arg:   buf.String(),
+			parse: parseLiteral(buf.String(), cfg),
+		}
+		segs = append(segs, seg)
+		buf.Reset()
+	}
 	for {
+		offset := int(str.Size()) - str.Len()
+		if buf.Len() == 0 {
+			pos = offset
+		}
 		last, _, _ = str.ReadRune()
 		if until(last) {
 			break
@@ -371,82 +1027,184 @@ func parsePatternUntil(str *bytes.Reader, until func(rune) bool) (rune, parsefun
 				buf.WriteRune(last)
 				continue
 			}
-			if buf.Len() > 0 {
-				pfs = append(pfs, parseLiteral(buf.String()))
-				buf.Reset()
+			literal()
+			if last == '{' {
+				others, err := parseMacro(str, cfg)
+				if err != nil {
+					return last, nil, err
+				}
+				segs = append(segs, relocate(others, offset)...)
+				continue
 			}
-			fn, err := parseSpecifier(str, last)
+			seg, err := parseSpecifier(str, last, cfg)
 			if err != nil {
 				return last, nil, err
 			}
-			pfs = append(pfs, fn)
+			seg.pos = offset
+			segs = append(segs, seg)
 		} else if last == '@' {
-			fn, err := parseAlternative(str)
+			literal()
+			seg, err := parseAlternative(str, cfg)
 			if err != nil {
 				return last, nil, err
 			}
-			pfs = append(pfs, fn)
+			seg.pos = offset
+			segs = append(segs, seg)
 		} else if last == '\\' {
 			last, _, _ = str.ReadRune()
 			if !isEscape(last) {
-				return last, nil, fmt.Errorf("%w: invalid escaped character %c", last)
+				return last, nil, fmt.Errorf("%w: invalid escaped character %c", ErrSyntax, last)
 			}
 			buf.WriteRune(last)
 		} else {
 			buf.WriteRune(last)
 		}
 	}
-	if buf.Len() > 0 {
-		pfs = append(pfs, parseLiteral(buf.String()))
+	literal()
+	return last, segs, nil
+}
+
+func parseMacro(str *bytes.Reader, cfg *config) ([]segment, error) {
+	name, _ := parseString(str, 0, func(r rune) bool { return r != '}' && !isEOL(r) })
+	if r, _, _ := str.ReadRune(); r != '}' {
+		return nil, fmt.Errorf("%w(macro): missing }", ErrSyntax)
+	}
+	body, ok := cfg.macros[name]
+	if !ok {
+		return nil, fmt.Errorf("%w(macro): %s not defined", ErrSyntax, name)
+	}
+	for _, n := range cfg.expanding {
+		if n == name {
+			cycle := append(cfg.expanding[:len(cfg.expanding):len(cfg.expanding)], name)
+			return nil, fmt.Errorf("%w(macro): cycle detected (%s)", ErrSyntax, strings.Join(cycle, " -> "))
+		}
 	}
-	return last, mergeParse(pfs), nil
+	cfg.expanding = append(cfg.expanding, name)
+	defer func() {
+		cfg.expanding = cfg.expanding[:len(cfg.expanding)-1]
+	}()
+
+	var (
+		until = func(r rune) bool { return r == 0 }
+		sub   = bytes.NewReader([]byte(body))
+	)
+	_, segs, err := parsePatternUntil(sub, until, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("macro %s: %w", name, err)
+	}
+	return segs, nil
 }
 
-func parseSpecifier(str *bytes.Reader, r rune) (parsefunc, error) {
+// relocate gives the position of a macro reference to the segments of the
+// expanded macro.
+func relocate(segs []segment, pos int) []segment {
+	for i := range segs {
+		segs[i].pos = pos
+		for j := range segs[i].alts {
+			relocate(segs[i].alts[j], pos)
+		}
+	}
+	return segs
+}
+
+func parseSpecifier(str *bytes.Reader, r rune, cfg *config) (segment, error) {
+	var (
+		seg = segment{spec: r}
+		err error
+	)
 	switch r {
 	case 't':
-		arg, err := parseArgument(str, rfcPattern, "time")
-		if err != nil {
-			return nil, err
+		if seg.arg, err = parseArgument(str, rfcPattern, "time"); err != nil {
+			break
 		}
-		return parseTime(arg)
+		seg.parse, err = parseTime(seg.arg, cfg)
 	case 'b':
-		return parseBlank(), nil
-	case 'n':
-		return parseProcess(), nil
+		seg.parse = parseBlank()
+	case 'n', 'u', 'g':
+		var accept func(rune) bool
+		if seg.arg, err = parseClassArgument(str); err != nil {
+			break
+		}
+		if accept, err = cfg.class(seg.arg); err != nil {
+			break
+		}
+		switch r {
+		case 'n':
+			seg.parse = parseProcess(accept)
+		case 'u':
+			seg.parse = parseUser(accept)
+		default:
+			seg.parse = parseGroup(accept)
+		}
 	case 'p':
-		return parsePID(), nil
-	case 'u':
-		return parseUser(), nil
-	case 'g':
-		return parseGroup(), nil
+		seg.parse = parsePID()
 	case 'h':
-		arg, err := parseArgument(str, "%f", "host")
-		if err != nil {
-			return nil, err
+		if seg.arg, err = parseNestedArgument(str, "%f", "host"); err != nil {
+			break
 		}
-		return parseHost(arg)
+		seg.parse, err = parseHost(seg.arg, cfg)
 	case 'l':
-		arg, err := parseArgument(str, "-", "level")
-		if err != nil {
-			return nil, err
+		if seg.arg, err = parseArgument(str, "-", "level"); err != nil {
+			break
 		}
-		if arg == "-" {
-			arg = ""
+		if seg.arg == "-" {
+			seg.arg = ""
 		}
-		return parseLevel(arg)
+		seg.parse, err = parseLevel(seg.arg, cfg)
+	case 'P':
+		seg.parse = parsePriority(cfg)
+	case 'c':
+		seg.parse = parseSource()
 	case 'm':
-		return parseMessage(), nil
+		seg.parse = parseMessage()
 	case 'w':
-		return parseWord(""), nil
+		seg.parse = parseWord("")
+	case 'r':
+		if seg.arg, err = parseExpression(str, "regexp"); err != nil {
+			break
+		}
+		seg.parse, seg.names, err = parseRegexp(seg.arg)
 	case '*':
-		return parseDiscard(peek(str)), nil
+		seg.parse = parseDiscard(mergeParse(nil))
 	default:
-		return nil, fmt.Errorf("%w: unsupported specifier %%%c", ErrSyntax, r)
+		err = fmt.Errorf("%w: unsupported specifier %%%c", ErrSyntax, r)
+	}
+	return seg, err
+}
+
+func mergeParse(segs []segment) parsefunc {
+	return chainParse(segs, nil)
+}
+
+// chainParse merges the segments into one parsefunc followed by next. Discards
+// and alternatives are given everything that follows them in the pattern so
+// that they can try another way to match when the rest of the pattern fails.
+func chainParse(segs []segment, next parsefunc) parsefunc {
+	var pfs []parsefunc
+	for i, s := range segs {
+		switch s.spec {
+		case '*':
+			pfs = append(pfs, parseDiscard(chainParse(segs[i+1:], next)))
+		case '@':
+			var (
+				rest = chainParse(segs[i+1:], next)
+				alts = make([]parsefunc, 0, len(s.alts))
+			)
+			for _, a := range s.alts {
+				alts = append(alts, chainParse(a, rest))
+			}
+			fn, _ := parseAlt(alts, s.names)
+			pfs = append(pfs, fn)
+		default:
+			pfs = append(pfs, s.parse)
+			continue
+		}
+		next = nil
+		break
+	}
+	if next != nil {
+		pfs = append(pfs, next)
 	}
-}
-
-func mergeParse(pfs []parsefunc) parsefunc {
 	return func(e *Entry, r *bytes.Reader) error {
 		for _, pf := range pfs {
 			if err := pf(e, r); err != nil {
@@ -458,22 +1216,43 @@ func mergeParse(pfs []parsefunc) parsefunc {
 }
 
 func parseArgument(str *bytes.Reader, option, what string) (string, error) {
-	r, _, _ := str.ReadRune()
+	return readArgument(str, option, what, false)
+}
+
+// parseNestedArgument is like parseArgument but the argument can contain
+// balanced parentheses (eg: host pattern with a hostname class).
+func parseNestedArgument(str *bytes.Reader, option, what string) (string, error) {
+	return readArgument(str, option, what, true)
+}
+
+func readArgument(str *bytes.Reader, option, what string, nested bool) (string, error) {
+	r, _, err := str.ReadRune()
 	if r != '(' {
 		if option != "" {
-			return option, str.UnreadRune()
+			if err == nil {
+				str.UnreadRune()
+			}
+			return option, nil
 		}
 		return "", fmt.Errorf("%w(%s): missing (", ErrSyntax, what)
 	}
-	var buf bytes.Buffer
+	var (
+		buf   bytes.Buffer
+		depth int
+	)
 	for str.Len() > 0 {
 		r, _, _ := str.ReadRune()
-		if r == ')' {
-			return buf.String(), nil
+		if r == '(' && nested {
+			depth++
+		} else if r == ')' {
+			if depth == 0 {
+				return buf.String(), nil
+			}
+			depth--
 		} else if r == '\\' {
 			r, _, _ = str.ReadRune()
 			if !isEscape(r) {
-				return "", fmt.Errorf("%w: invalid escaped character %c", r)
+				return "", fmt.Errorf("%w: invalid escaped character %c", ErrSyntax, r)
 			}
 		}
 		buf.WriteRune(r)
@@ -484,50 +1263,152 @@ func parseArgument(str *bytes.Reader, option, what string) (string, error) {
 	return "", fmt.Errorf("%w(%s): missing )", ErrSyntax, what)
 }
 
-func parseAlternative(str *bytes.Reader) (parsefunc, error) {
+// parseClassArgument reads the optional class argument of a specifier. The
+// argument is only recognized when it starts with a named class (eg:
+// ([:alnum:]./)), otherwise the parenthesis is left to the rest of the pattern
+// and an empty string is returned.
+func parseClassArgument(str *bytes.Reader) (string, error) {
+	seek, err := str.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	prefix := make([]byte, 3)
+	n, _ := str.Read(prefix)
+	if _, err := str.Seek(seek, io.SeekStart); err != nil {
+		return "", err
+	}
+	if string(prefix[:n]) != "([:" {
+		return "", nil
+	}
+	return parseArgument(str, "", "class")
+}
+
+// parseExpression reads the argument of a specifier whose content follows its
+// own syntax (eg: regular expression). Contrary to parseArgument, escaped
+// characters are kept as is, parentheses are balanced and the argument length is
+// not limited.
+func parseExpression(str *bytes.Reader, what string) (string, error) {
+	r, _, _ := str.ReadRune()
+	if r != '(' {
+		return "", fmt.Errorf("%w(%s): missing (", ErrSyntax, what)
+	}
+	var (
+		buf   bytes.Buffer
+		depth int
+		class bool
+	)
+	for str.Len() > 0 {
+		r, _, _ := str.ReadRune()
+		switch {
+		case r == '\\':
+			buf.WriteRune(r)
+			r, _, _ = str.ReadRune()
+		case class:
+			class = r != ']'
+		case r == '[':
+			class = true
+			buf.WriteRune(r)
+			if r = peek(str); r == '^' {
+				str.ReadRune()
+				buf.WriteRune(r)
+			}
+			if r = peek(str); r != ']' {
+				continue
+			}
+			str.ReadRune()
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth == 0 {
+				return buf.String(), nil
+			}
+			depth--
+		}
+		buf.WriteRune(r)
+	}
+	return "", fmt.Errorf("%w(%s): missing )", ErrSyntax, what)
+}
+
+func parseAlternative(str *bytes.Reader, cfg *config) (segment, error) {
+	seg := segment{spec: '@'}
 	r, _, _ := str.ReadRune()
 	if r != '(' {
-		return nil, fmt.Errorf("%w: missing (", ErrSyntax)
+		return seg, fmt.Errorf("%w: missing (", ErrSyntax)
 	}
 	var (
 		pfs   []parsefunc
-		until = func(r rune) bool { return r == '|' || r == ')' }
+		until = func(r rune) bool { return r == '|' || r == ')' || r == 0 }
 	)
 	for {
-		last, fn, err := parsePatternUntil(str, until)
+		name, err := parseBranchName(str)
 		if err != nil {
-			return nil, err
+			return seg, err
+		}
+		if name == "" {
+			name = strconv.Itoa(len(seg.alts))
+		}
+		last, segs, err := parsePatternUntil(str, until, cfg)
+		if err != nil {
+			return seg, err
+		}
+		if last == 0 {
+			return seg, fmt.Errorf("%w: missing )", ErrSyntax)
 		}
 		if last != '|' && last != ')' {
-			return nil, fmt.Errorf("%w: unexpected character %c", ErrSyntax, last)
+			return seg, fmt.Errorf("%w: unexpected character %c", ErrSyntax, last)
 		}
-		pfs = append(pfs, fn)
+		seg.alts = append(seg.alts, segs)
+		seg.names = append(seg.names, name)
+		pfs = append(pfs, mergeParse(segs))
 		if last == ')' {
 			break
 		}
 	}
-	return parseAlt(pfs)
+	var err error
+	seg.parse, err = parseAlt(pfs, seg.names)
+	return seg, err
 }
 
-func parseAlt(pfs []parsefunc) (parsefunc, error) {
+// parseBranchName reads the optional label of a branch written as ?<name> at
+// the start of the branch.
+func parseBranchName(str *bytes.Reader) (string, error) {
+	seek, err := str.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if r, _, _ := str.ReadRune(); r == '?' {
+		if r, _, _ := str.ReadRune(); r == '<' {
+			name, _ := parseString(str, 0, func(r rune) bool { return r != '>' && !isEOL(r) })
+			if r, _, _ := str.ReadRune(); r != '>' || name == "" {
+				return "", fmt.Errorf("%w: invalid branch label", ErrSyntax)
+			}
+			return name, nil
+		}
+	}
+	_, err = str.Seek(seek, io.SeekStart)
+	return "", err
+}
+
+// parseAlt tries each branch in turn until one matches. A failing branch
+// leaves the entry as it was before trying it. The name of the branch that
+// matched is appended to the Branches of the entry.
+func parseAlt(pfs []parsefunc, names []string) (parsefunc, error) {
 	if len(pfs) == 0 {
 		return nil, fmt.Errorf("%w: empty alternatives", ErrSyntax)
 	}
 	fn := func(e *Entry, r *bytes.Reader) error {
-		var (
-			err  error
-			seek int64
-		)
-		seek, err = r.Seek(0, io.SeekCurrent)
+		seek, err := r.Seek(0, io.SeekCurrent)
 		if err != nil {
 			return err
 		}
-		for _, pf := range pfs {
+		saved := e.snapshot()
+		for i, pf := range pfs {
+			e.Branches = append(e.Branches, names[i])
 			if err = pf(e, r); err == nil {
 				break
 			}
-			seek, err = r.Seek(seek, io.SeekStart)
-			if err != nil {
+			e.restore(saved)
+			if _, err := r.Seek(seek, io.SeekStart); err != nil {
 				return err
 			}
 		}
@@ -536,7 +1417,21 @@ func parseAlt(pfs []parsefunc) (parsefunc, error) {
 	return fn, nil
 }
 
-func parseLevel(level string) (parsefunc, error) {
+func parseLevel(level string, cfg *config) (parsefunc, error) {
+	levels := splitLevels(level, cfg)
+	fn := func(e *Entry, r *bytes.Reader) error {
+		str, _ := parseString(r, 0, cfg.letter)
+		e.Level = cfg.level(str)
+		x := sort.SearchStrings(levels, e.Level)
+		if len(levels) > 0 && (x >= len(levels) || levels[x] != e.Level) {
+			return ErrPattern
+		}
+		return nil
+	}
+	return fn, nil
+}
+
+func splitLevels(level string, cfg *config) []string {
 	level = strings.Map(func(r rune) rune {
 		if isBlank(r) {
 			return -1
@@ -546,22 +1441,125 @@ func parseLevel(level string) (parsefunc, error) {
 	var levels []string
 	if level != "" {
 		levels = strings.Split(level, ",")
+		for i := range levels {
+			levels[i] = cfg.level(levels[i])
+		}
 		sort.Strings(levels)
-
 	}
-	fn := func(e *Entry, r *bytes.Reader) error {
-		e.Level, _ = parseString(r, 0, isLetter)
-		x := sort.SearchStrings(levels, e.Level)
-		if len(levels) > 0 && (x >= len(levels) || levels[x] != e.Level) {
+	return levels
+}
+
+// levelAliases maps the usual spellings of levels to their canonical names.
+var levelAliases = map[string]string{
+	"trace":         "TRACE",
+	"trc":           "TRACE",
+	"verbose":       "TRACE",
+	"v":             "TRACE",
+	"debug":         "DEBUG",
+	"dbg":           "DEBUG",
+	"d":             "DEBUG",
+	"info":          "INFO",
+	"inf":           "INFO",
+	"information":   "INFO",
+	"informational": "INFO",
+	"i":             "INFO",
+	"notice":        "NOTICE",
+	"note":          "NOTICE",
+	"n":             "NOTICE",
+	"warning":       "WARNING",
+	"warn":          "WARNING",
+	"wrn":           "WARNING",
+	"w":             "WARNING",
+	"error":         "ERROR",
+	"err":           "ERROR",
+	"e":             "ERROR",
+	"critical":      "CRITICAL",
+	"crit":          "CRITICAL",
+	"crt":           "CRITICAL",
+	"c":             "CRITICAL",
+	"alert":         "ALERT",
+	"a":             "ALERT",
+	"emergency":     "EMERGENCY",
+	"emerg":         "EMERGENCY",
+	"panic":         "EMERGENCY",
+	"fatal":         "FATAL",
+	"ftl":           "FATAL",
+	"f":             "FATAL",
+}
+
+const fieldFacility = "facility"
+
+var severities = []string{
+	"EMERGENCY",
+	"ALERT",
+	"CRITICAL",
+	"ERROR",
+	"WARNING",
+	"NOTICE",
+	"INFO",
+	"DEBUG",
+}
+
+var facilities = []string{
+	"kern",
+	"user",
+	"mail",
+	"daemon",
+	"auth",
+	"syslog",
+	"lpr",
+	"news",
+	"uucp",
+	"cron",
+	"authpriv",
+	"ftp",
+	"ntp",
+	"security",
+	"console",
+	"solaris-cron",
+	"local0",
+	"local1",
+	"local2",
+	"local3",
+	"local4",
+	"local5",
+	"local6",
+	"local7",
+}
+
+func parsePriority(cfg *config) parsefunc {
+	return func(e *Entry, r *bytes.Reader) error {
+		if c, _, _ := r.ReadRune(); c != '<' {
+			return ErrPattern
+		}
+		if c := peek(r); !isDigit(c) {
+			return ErrPattern
+		}
+		var pri int
+		if err := parseInt(&pri, 0, r, isDigit); err != nil {
+			return err
+		}
+		if c, _, _ := r.ReadRune(); c != '>' {
+			return ErrPattern
+		}
+		if pri >= len(facilities)*len(severities) {
 			return ErrPattern
 		}
+		if e.Fields == nil {
+			e.Fields = make(map[string]string)
+		}
+		e.Fields[fieldFacility] = facilities[pri/len(severities)]
+		e.Level = cfg.level(severities[pri%len(severities)])
 		return nil
 	}
-	return fn, nil
 }
 
-func parseTime(str string) (parsefunc, error) {
-	parse, err := parseTimePattern(str)
+func parseTime(str string, cfg *config) (parsefunc, error) {
+	str, err := translateLayout(str)
+	if err != nil {
+		return nil, err
+	}
+	parse, err := parseTimePattern(str, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -571,15 +1569,28 @@ func parseTime(str string) (parsefunc, error) {
 			err = parse(&w, r)
 		)
 		if err == nil {
+			if !cfg.lenient {
+				if err := w.Check(); err != nil {
+					return err
+				}
+			}
+			if w.Loc == nil {
+				w.Loc = cfg.location
+			}
+			w.Anchor = cfg.anchor
 			e.When = w.Time()
+			e.stamp = nil
+			if w.Year == 0 && !w.Epoch && !w.Relative {
+				e.stamp = &w
+			}
 		}
 		return err
 	}
 	return fn, nil
 }
 
-func parseHost(str string) (parsefunc, error) {
-	parse, err := parseHostPattern(str)
+func parseHost(str string, cfg *config) (parsefunc, error) {
+	parse, err := parseHostPattern(str, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -603,18 +1614,78 @@ func parsePID() parsefunc {
 	}
 }
 
-func parseLiteral(str string) parsefunc {
-	return func(e *Entry, r *bytes.Reader) error {
-		pat := strings.NewReader(str)
-		for pat.Len() > 0 {
-			w, _, _ := pat.ReadRune()
-			g, _, _ := r.ReadRune()
-			if w != g {
-				return ErrPattern
-			}
-		}
-		return nil
+func parseLiteral(str string, cfg *config) parsefunc {
+	equal := func(w, g rune) bool { return w == g }
+	if cfg.fold {
+		equal = equalFold
+	}
+	return func(e *Entry, r *bytes.Reader) error {
+		pat := strings.NewReader(str)
+		for pat.Len() > 0 {
+			w, _, _ := pat.ReadRune()
+			if cfg.blanks && isBlank(w) {
+				if !isBlank(peek(r)) {
+					return ErrPattern
+				}
+				parseString(pat, 0, isBlank)
+				parseString(r, 0, isBlank)
+				continue
+			}
+			g, _, _ := r.ReadRune()
+			if !equal(w, g) {
+				return ErrPattern
+			}
+		}
+		return nil
+	}
+}
+
+// parseSource parses a location written as file:line. The file ends at the
+// last :line of the location, a :column that follows it is left unconsumed.
+// Digits followed by anything else than a colon (eg: the port of an url) are
+// part of the file.
+func parseSource() parsefunc {
+	return func(e *Entry, r *bytes.Reader) error {
+		seek, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		str, _ := parseString(r, 0, func(r rune) bool { return !isBlank(r) && !isEOL(r) })
+
+		var spans [][2]int
+		for i := 1; i < len(str); i++ {
+			if str[i] != ':' {
+				continue
+			}
+			j := i + 1
+			for j < len(str) && isDigit(rune(str[j])) {
+				j++
+			}
+			if j > i+1 && (j == len(str) || str[j] == ':') {
+				spans = append(spans, [2]int{i, j})
+			}
+		}
+		if len(spans) == 0 {
+			return ErrPattern
+		}
+		last := spans[len(spans)-1]
+		if n := len(spans); n > 1 && spans[n-2][1] == last[0] {
+			last = spans[n-2]
+		}
+		if _, err := r.Seek(seek+int64(last[1]), io.SeekStart); err != nil {
+			return err
+		}
+		e.Source = str[:last[0]]
+		e.SourceLine, _ = strconv.Atoi(str[last[0]+1 : last[1]])
+		return nil
+	}
+}
+
+func parseEnd(_ *Entry, r *bytes.Reader) error {
+	if r.Len() > 0 {
+		return ErrPattern
 	}
+	return nil
 }
 
 func parseMessage() parsefunc {
@@ -661,33 +1732,103 @@ func parseWord(str string) parsefunc {
 	}
 }
 
-func parseUser() parsefunc {
-	return func(e *Entry, r *bytes.Reader) error {
-		e.User, _ = parseString(r, 0, isAlpha)
+// parseRegexp returns the parsefunc matching expr and the names of its named
+// subexpressions.
+func parseRegexp(expr string) (parsefunc, []string, error) {
+	rx, err := regexp.Compile("^(?:" + expr + ")")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w(regexp): %s", ErrSyntax, err)
+	}
+	var (
+		names = rx.SubexpNames()
+		named []string
+	)
+	for _, n := range names {
+		if n != "" {
+			named = append(named, n)
+		}
+	}
+	fn := func(e *Entry, r *bytes.Reader) error {
+		seek, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		rest := make([]byte, r.Len())
+		r.Read(rest)
+
+		ix := rx.FindSubmatchIndex(rest)
+		if ix == nil {
+			return ErrPattern
+		}
+		if _, err := r.Seek(seek+int64(ix[1]), io.SeekStart); err != nil {
+			return err
+		}
+		if len(named) == 0 {
+			if ix[1] > 0 {
+				e.Words = append(e.Words, string(rest[:ix[1]]))
+			}
+			return nil
+		}
+		for i, n := range names {
+			if n == "" || ix[2*i] < 0 {
+				continue
+			}
+			if e.Fields == nil {
+				e.Fields = make(map[string]string)
+			}
+			e.Fields[n] = string(rest[ix[2*i]:ix[2*i+1]])
+		}
 		return nil
 	}
+	return fn, named, nil
 }
 
-func parseGroup() parsefunc {
+func parseUser(accept func(rune) bool) parsefunc {
 	return func(e *Entry, r *bytes.Reader) error {
-		e.Group, _ = parseString(r, 0, isAlpha)
+		e.User, _ = parseString(r, 0, accept)
 		return nil
 	}
 }
 
-func parseDiscard(next rune) parsefunc {
-	accept := func(r rune) bool {
-		return r != next
-	}
-	return func(_ *Entry, r *bytes.Reader) error {
-		parseString(r, 0, accept)
+func parseGroup(accept func(rune) bool) parsefunc {
+	return func(e *Entry, r *bytes.Reader) error {
+		e.Group, _ = parseString(r, 0, accept)
 		return nil
 	}
 }
 
-func parseProcess() parsefunc {
+// parseDiscard skips as few characters as possible for rest to match what
+// follows them. Changes made to the entry by a failed attempt are undone before
+// trying at the next position.
+func parseDiscard(rest parsefunc) parsefunc {
+	return func(e *Entry, r *bytes.Reader) error {
+		seek, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		saved := e.snapshot()
+		for {
+			err := rest(e, r)
+			if err == nil || !(errors.Is(err, ErrPattern) || errors.Is(err, io.EOF)) {
+				return err
+			}
+			e.restore(saved)
+			if _, err := r.Seek(seek, io.SeekStart); err != nil {
+				return err
+			}
+			if _, _, err := r.ReadRune(); err != nil {
+				return ErrPattern
+			}
+			if seek, err = r.Seek(0, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func parseProcess(accept func(rune) bool) parsefunc {
 	return func(e *Entry, r *bytes.Reader) error {
-		e.Process, _ = parseString(r, 0, isAlpha)
+		e.Process, _ = parseString(r, 0, accept)
 		return nil
 	}
 }
@@ -699,30 +1840,10 @@ func parseBlank() parsefunc {
 	}
 }
 
-var days = []string{
-	"mon",
-	"tue",
-	"wed",
-	"thu",
-	"fri",
-	"sat",
-	"sun",
-}
-
-var months = []string{
-	"jan",
-	"feb",
-	"mar",
-	"apr",
-	"may",
-	"jun",
-	"jul",
-	"aug",
-	"sep",
-	"oct",
-	"nov",
-	"dec",
-}
+const (
+	meridiemAM = iota + 1
+	meridiemPM
+)
 
 const (
 	isoPattern = "%y-%m-%d %H:%M:%S%Z"
@@ -738,14 +1859,29 @@ type when struct {
 	Sec  int
 	Frac int
 
-	Zone    int
-	YearDay int
-	Unix    int
+	Loc      *time.Location
+	YearDay  int
+	Unix     int64
+	Epoch    bool
+	Meridiem int
+	// day of week from 1 (monday) to 7, 0 when not parsed
+	WeekDay int
+
+	Elapsed  time.Duration
+	Relative bool
+	Anchor   time.Time
 }
 
 func (w when) Time() time.Time {
-	if w.Unix != 0 {
-		return time.Unix(int64(w.Unix), 0)
+	if w.Relative {
+		return w.Anchor.Add(w.Elapsed)
+	}
+	if w.Epoch {
+		t := time.Unix(w.Unix, int64(w.Frac))
+		if w.Loc != nil {
+			t = t.In(w.Loc)
+		}
+		return t
 	}
 	if w.Year == 0 {
 		w.Year++
@@ -756,9 +1892,19 @@ func (w when) Time() time.Time {
 	if w.Day == 0 {
 		w.Day++
 	}
+	switch w.Meridiem {
+	case meridiemAM:
+		if w.Hour == 12 {
+			w.Hour = 0
+		}
+	case meridiemPM:
+		if w.Hour < 12 {
+			w.Hour += 12
+		}
+	}
 	zone := time.UTC
-	if w.Zone != 0 {
-		zone = time.FixedZone("", w.Zone)
+	if w.Loc != nil {
+		zone = w.Loc
 	}
 	t := time.Date(w.Year, time.Month(w.Mon), w.Day, w.Hour, w.Min, w.Sec, w.Frac, zone)
 	if w.YearDay > 0 {
@@ -767,7 +1913,59 @@ func (w when) Time() time.Time {
 	return t
 }
 
-func parseTimePattern(pattern string) (whenfunc, error) {
+// Check reports whether the date parsed exists in the calendar. A missing year
+// is considered as a leap year and the day of week parsed is only checked when
+// the year is known.
+func (w when) Check() error {
+	if w.Epoch || w.Relative {
+		return nil
+	}
+	year := w.Year
+	if year == 0 {
+		year = 2000
+	}
+	leap := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay() == 366
+	if w.YearDay > 365 && !leap {
+		return ErrPattern
+	}
+	if w.Mon > 0 && w.Day > 0 {
+		t := time.Date(year, time.Month(w.Mon), w.Day, 0, 0, 0, 0, time.UTC)
+		if t.Day() != w.Day {
+			return ErrPattern
+		}
+	}
+	if w.WeekDay > 0 && w.Year > 0 && (w.Mon > 0 || w.YearDay > 0) {
+		t := w.Time()
+		if day := int(t.Weekday()); (day+6)%7+1 != w.WeekDay {
+			return ErrPattern
+		}
+	}
+	return nil
+}
+
+// referenceYear returns the year of ref or the previous one if w is later
+// than ref by more than a day in the year of ref.
+func referenceYear(w when, ref time.Time) int {
+	year := ref.Year()
+	if t, _ := w.inYear(year, true); t.After(ref.Add(24 * time.Hour)) {
+		year--
+	}
+	return year
+}
+
+// inYear returns the time of w in the given year. Unless lenient is set, the
+// date is checked in that year first.
+func (w when) inYear(year int, lenient bool) (time.Time, error) {
+	w.Year = year
+	if !lenient {
+		if err := w.Check(); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return w.Time(), nil
+}
+
+func parseTimePattern(pattern string, cfg *config) (whenfunc, error) {
 	if pattern == "" {
 		pattern = isoPattern
 	}
@@ -789,14 +1987,21 @@ func parseTimePattern(pattern string) (whenfunc, error) {
 				buf.Reset()
 			}
 			switch r {
+			case '-':
+				r, _, _ = str.ReadRune()
+				get, n := whenField(r)
+				if get == nil {
+					return nil, fmt.Errorf("%w(time): unpadded specifier %c", ErrSyntax, r)
+				}
+				wfs = append(wfs, parseUnpadded(get, n))
 			case 'I':
-				fn, err := parseTimePattern(isoPattern)
+				fn, err := parseTimePattern(isoPattern, cfg)
 				if err != nil {
 					return nil, err
 				}
 				wfs = append(wfs, fn)
 			case 'R':
-				fn, err := parseTimePattern(isoPattern)
+				fn, err := parseTimePattern(isoPattern, cfg)
 				if err != nil {
 					return nil, err
 				}
@@ -807,27 +2012,49 @@ func parseTimePattern(pattern string) (whenfunc, error) {
 				wfs = append(wfs, parseMonth)
 			case 'd':
 				wfs = append(wfs, parseDay)
+			case 'e':
+				wfs = append(wfs, parseDayBlank)
 			case 'j':
 				wfs = append(wfs, parseDOY)
-			case 'a':
-				wfs = append(wfs, parseDayStr)
-			case 'b':
-				wfs = append(wfs, parseMonthStr)
+			case 'a', 'A':
+				wfs = append(wfs, parseDayName(cfg, r == 'A'))
+			case 'b', 'B':
+				wfs = append(wfs, parseMonthName(cfg, r == 'B'))
 			case 's':
 				wfs = append(wfs, parseTimestamp)
+			case 'r':
+				wfs = append(wfs, parseElapsed)
+			case 'L':
+				wfs = append(wfs, parseEpoch(time.Millisecond))
+			case 'U':
+				wfs = append(wfs, parseEpoch(time.Microsecond))
+			case 'N':
+				wfs = append(wfs, parseEpoch(time.Nanosecond))
 			case 'H':
 				wfs = append(wfs, parseHour)
+			case 'K':
+				wfs = append(wfs, parseHour)
+			case 'p':
+				wfs = append(wfs, parseMeridiem)
 			case 'M':
 				wfs = append(wfs, parseMinute)
 			case 'S':
 				wfs = append(wfs, parseSecond)
 			case 'f':
 				wfs = append(wfs, parseFraction)
+			case 'F':
+				wfs = append(wfs, parseOptionalFraction)
 			case 'Z':
 				wfs = append(wfs, parseZone)
+			case 'z':
+				wfs = append(wfs, parseZoneName)
 			default:
 				return nil, fmt.Errorf("%w(time): unknown specifier %c", ErrSyntax, r)
 			}
+			if rg, ok := whenRanges[r]; ok && !cfg.lenient {
+				get, _ := whenField(r)
+				wfs[len(wfs)-1] = checkRange(wfs[len(wfs)-1], get, rg[0], rg[1])
+			}
 		} else {
 			buf.WriteRune(r)
 		}
@@ -861,41 +2088,38 @@ func parseDay(w *when, r *bytes.Reader) error {
 	return parseInt(&w.Day, 2, r, isDigit)
 }
 
-func parseDayStr(w *when, r *bytes.Reader) error {
-	day, err := parseString(r, 3, isLetter)
-	if err != nil {
-		return err
-	}
-	day = strings.ToLower(day)
-	x := sort.SearchStrings(days, day)
-	if x >= len(days) || days[x] != day {
-		return ErrPattern
+func parseDayBlank(w *when, r *bytes.Reader) error {
+	if isBlank(peek(r)) {
+		r.ReadRune()
+		return parseInt(&w.Day, 1, r, isDigit)
 	}
-	return nil
+	return parseDay(w, r)
 }
 
 func parseMonth(w *when, r *bytes.Reader) error {
 	return parseInt(&w.Mon, 2, r, isDigit)
 }
 
-func parseMonthStr(w *when, r *bytes.Reader) error {
-	month, err := parseString(r, 3, isLetter)
+func parseHour(w *when, r *bytes.Reader) error {
+	return parseInt(&w.Hour, 2, r, isDigit)
+}
+
+func parseMeridiem(w *when, r *bytes.Reader) error {
+	str, err := parseString(r, 2, isLetter)
 	if err != nil {
 		return err
 	}
-	month = strings.ToLower(month)
-	x := sort.SearchStrings(months, month)
-	if x >= len(months) || months[x] != month {
+	switch strings.ToLower(str) {
+	case "am":
+		w.Meridiem = meridiemAM
+	case "pm":
+		w.Meridiem = meridiemPM
+	default:
 		return ErrPattern
 	}
-	w.Mon = x + 1
 	return nil
 }
 
-func parseHour(w *when, r *bytes.Reader) error {
-	return parseInt(&w.Hour, 2, r, isDigit)
-}
-
 func parseMinute(w *when, r *bytes.Reader) error {
 	return parseInt(&w.Min, 2, r, isDigit)
 }
@@ -904,53 +2128,291 @@ func parseSecond(w *when, r *bytes.Reader) error {
 	return parseInt(&w.Sec, 2, r, isDigit)
 }
 
+// whenField returns the component of when set by the numeric specifier r and
+// its number of digits when padded.
+func whenField(r rune) (func(*when) *int, int) {
+	switch r {
+	case 'd', 'e':
+		return func(w *when) *int { return &w.Day }, 2
+	case 'm':
+		return func(w *when) *int { return &w.Mon }, 2
+	case 'j':
+		return func(w *when) *int { return &w.YearDay }, 3
+	case 'H', 'K':
+		return func(w *when) *int { return &w.Hour }, 2
+	case 'M':
+		return func(w *when) *int { return &w.Min }, 2
+	case 'S':
+		return func(w *when) *int { return &w.Sec }, 2
+	default:
+		return nil, 0
+	}
+}
+
+// whenRanges gives the bounds of the values accepted by the numeric
+// specifiers.
+var whenRanges = map[rune][2]int{
+	'd': {1, 31},
+	'e': {1, 31},
+	'm': {1, 12},
+	'j': {1, 366},
+	'H': {0, 23},
+	'K': {1, 12},
+	'M': {0, 59},
+	'S': {0, 60},
+}
+
+func checkRange(fn whenfunc, get func(*when) *int, min, max int) whenfunc {
+	return func(w *when, r *bytes.Reader) error {
+		if err := fn(w, r); err != nil {
+			return err
+		}
+		if v := *get(w); v < min || v > max {
+			return ErrPattern
+		}
+		return nil
+	}
+}
+
+// parseUnpadded reads at least one and up to n digits.
+func parseUnpadded(get func(*when) *int, n int) whenfunc {
+	return func(w *when, r *bytes.Reader) error {
+		var i, j int
+		for ; i < n; i++ {
+			c, _, err := r.ReadRune()
+			if err != nil {
+				break
+			}
+			if !isDigit(c) {
+				r.UnreadRune()
+				break
+			}
+			j = j*10 + int(c-'0')
+		}
+		if i == 0 {
+			return ErrPattern
+		}
+		*get(w) = j
+		return nil
+	}
+}
+
 func parseTimestamp(w *when, r *bytes.Reader) error {
-	return parseInt(&w.Unix, 0, r, isDigit)
+	if err := parseEpoch(time.Second)(w, r); err != nil {
+		return err
+	}
+	if peek(r) != '.' {
+		return nil
+	}
+	r.ReadRune()
+	if !isDigit(peek(r)) {
+		_, err := r.Seek(-1, io.SeekCurrent)
+		return err
+	}
+	return parseFraction(w, r)
+}
+
+func parseElapsed(w *when, r *bytes.Reader) error {
+	parseString(r, 0, isBlank)
+	var sec when
+	if err := parseTimestamp(&sec, r); err != nil {
+		return err
+	}
+	w.Elapsed = time.Duration(sec.Unix)*time.Second + time.Duration(sec.Frac)
+	w.Relative = true
+	return nil
+}
+
+// BootTime returns the time the system was booted. It can be given to
+// WithAnchor to read the timestamps of the kernel logs. It is only available
+// on Linux.
+func BootTime() (time.Time, error) {
+	buf, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if !strings.HasPrefix(line, "btime ") {
+			continue
+		}
+		sec, err := strconv.ParseInt(strings.TrimSpace(line[6:]), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, errors.New("boot time not found")
+}
+
+// parseEpoch parses a unix timestamp given in unit.
+func parseEpoch(unit time.Duration) whenfunc {
+	return func(w *when, r *bytes.Reader) error {
+		str, _ := parseString(r, 0, isDigit)
+		if str == "" {
+			return ErrPattern
+		}
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return ErrPattern
+		}
+		per := int64(time.Second / unit)
+		w.Unix, w.Frac, w.Epoch = n/per, int(n%per*int64(unit)), true
+		return nil
+	}
 }
 
 func parseZone(w *when, r *bytes.Reader) error {
 	switch z, _, _ := r.ReadRune(); z {
 	case 'Z':
+		w.Loc = time.UTC
 	case '+', '-':
-		w.Zone++
-		if z == '-' {
-			w.Zone *= -1
-		}
-		var i int
-		if err := parseInt(&i, 2, r, isDigit); err != nil {
+		var hour, min int
+		if err := parseInt(&hour, 2, r, isDigit); err != nil {
 			return err
 		}
-		w.Zone *= i * 60 * 60
-		if z := peek(r); z == ':' {
+		if k := peek(r); k == ':' {
 			r.ReadRune()
 		}
-		if z := peek(r); isDigit(z) {
-			err := parseInt(&i, 2, r, isDigit)
-			if err == nil {
-				w.Zone += i * 60
+		if k := peek(r); isDigit(k) {
+			if err := parseInt(&min, 2, r, isDigit); err != nil {
+				return err
 			}
-			return err
 		}
+		offset := hour*60*60 + min*60
+		if z == '-' {
+			offset = -offset
+		}
+		w.Loc = time.FixedZone("", offset)
 	default:
 		return ErrPattern
 	}
 	return nil
 }
 
-func parseFraction(w *when, r *bytes.Reader) error {
-	if err := parseInt(&w.Frac, 0, r, isDigit); err != nil {
-		return err
+// zoneAbbrs maps the usual abbreviations of time zones to their offset.
+// Abbreviations shared by several zones (eg: IST, CST, BST or AST) are left
+// out.
+var zoneAbbrs = map[string]time.Duration{
+	"UTC":  0,
+	"UT":   0,
+	"GMT":  0,
+	"WET":  0,
+	"WEST": 1 * time.Hour,
+	"CET":  1 * time.Hour,
+	"CEST": 2 * time.Hour,
+	"MET":  1 * time.Hour,
+	"MEST": 2 * time.Hour,
+	"EET":  2 * time.Hour,
+	"EEST": 3 * time.Hour,
+	"MSK":  3 * time.Hour,
+	"HKT":  8 * time.Hour,
+	"SGT":  8 * time.Hour,
+	"JST":  9 * time.Hour,
+	"KST":  9 * time.Hour,
+	"AWST": 8 * time.Hour,
+	"ACST": 9*time.Hour + 30*time.Minute,
+	"ACDT": 10*time.Hour + 30*time.Minute,
+	"AEST": 10 * time.Hour,
+	"AEDT": 11 * time.Hour,
+	"NZST": 12 * time.Hour,
+	"NZDT": 13 * time.Hour,
+	"HST":  -10 * time.Hour,
+	"AKST": -9 * time.Hour,
+	"AKDT": -8 * time.Hour,
+	"PST":  -8 * time.Hour,
+	"PDT":  -7 * time.Hour,
+	"MST":  -7 * time.Hour,
+	"MDT":  -6 * time.Hour,
+	"CDT":  -5 * time.Hour,
+	"EST":  -5 * time.Hour,
+	"EDT":  -4 * time.Hour,
+	"ADT":  -3 * time.Hour,
+	"NST":  -(3*time.Hour + 30*time.Minute),
+	"NDT":  -(2*time.Hour + 30*time.Minute),
+}
+
+// locations caches the locations loaded by name. Names that are not known
+// locations are cached too (with a nil location) so that lines that do not
+// match do not load the zone database again. The cache stops growing after
+// maxLocations names.
+var locations = struct {
+	sync.RWMutex
+	cache map[string]*time.Location
+}{cache: make(map[string]*time.Location)}
+
+const maxLocations = 1024
+
+func parseZoneName(w *when, r *bytes.Reader) error {
+	name, _ := parseString(r, 0, func(r rune) bool {
+		return isAlpha(r) || r == '/' || r == '+'
+	})
+	if name == "" {
+		return ErrPattern
 	}
-	switch {
-	case w.Frac < 1000:
-		w.Frac *= 1000 * 1000
-	case w.Frac < 1000*1000:
-		w.Frac *= 1000
-	default:
+	if offset, ok := zoneAbbrs[strings.ToUpper(name)]; ok {
+		w.Loc = time.FixedZone(strings.ToUpper(name), int(offset.Seconds()))
+		return nil
+	}
+	if !strings.Contains(name, "/") {
+		return ErrPattern
+	}
+	loc := loadLocation(name)
+	if loc == nil {
+		return ErrPattern
 	}
+	w.Loc = loc
 	return nil
 }
 
+// loadLocation returns the location of an IANA name (eg: Europe/Brussels) or
+// nil if name is not a location.
+func loadLocation(name string) *time.Location {
+	locations.RLock()
+	loc, ok := locations.cache[name]
+	locations.RUnlock()
+	if ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = nil
+	}
+	locations.Lock()
+	defer locations.Unlock()
+	if len(locations.cache) < maxLocations {
+		locations.cache[name] = loc
+	}
+	return loc
+}
+
+func parseOptionalFraction(w *when, r *bytes.Reader) error {
+	if k := peek(r); k != '.' && k != ',' {
+		return nil
+	}
+	r.ReadRune()
+	if !isDigit(peek(r)) {
+		_, err := r.Seek(-1, io.SeekCurrent)
+		return err
+	}
+	return parseFraction(w, r)
+}
+
+func parseFraction(w *when, r *bytes.Reader) error {
+	str, _ := parseString(r, 0, isDigit)
+	if str == "" {
+		return ErrPattern
+	}
+	if len(str) > 9 {
+		str = str[:9]
+	}
+	str += strings.Repeat("0", 9-len(str))
+	n, err := strconv.Atoi(str)
+	if err == nil {
+		w.Frac = n
+	}
+	return err
+}
+
 func parseWhenLiteral(str string) whenfunc {
 	return func(_ *when, r *bytes.Reader) error {
 		pat := strings.NewReader(str)
@@ -987,10 +2449,13 @@ func (h host) String() string {
 	if h.Name != "" {
 		return h.Name
 	}
+	if h.Port == 0 {
+		return h.Addr
+	}
 	return fmt.Sprintf("%s:%d", h.Addr, h.Port)
 }
 
-func parseHostPattern(pattern string) (hostfunc, error) {
+func parseHostPattern(pattern string, cfg *config) (hostfunc, error) {
 	var (
 		str = bytes.NewReader([]byte(pattern))
 		buf bytes.Buffer
@@ -1016,25 +2481,33 @@ func parseHostPattern(pattern string) (hostfunc, error) {
 			case 'p':
 				hfs = append(hfs, parsePort)
 			case 'f':
-				hfs = append(hfs, parseFQDN)
+				hfs = append(hfs, parseFQDN(cfg.alpha))
 			case 'h':
-				hfs = append(hfs, parseHostname)
+				arg, err := parseClassArgument(str)
+				if err != nil {
+					return nil, err
+				}
+				accept, err := cfg.class(arg)
+				if err != nil {
+					return nil, err
+				}
+				hfs = append(hfs, parseHostname(accept))
 			case 'm':
 				hfs = append(hfs, parseMask)
 			case 'F':
-				fn, err := parseHostPattern(ip4long)
+				fn, err := parseHostPattern(ip4long, cfg)
 				if err != nil {
 					return nil, err
 				}
 				hfs = append(hfs, fn)
 			case 'S':
-				fn, err := parseHostPattern(ip6long)
+				fn, err := parseHostPattern(ip6long, cfg)
 				if err != nil {
 					return nil, err
 				}
 				hfs = append(hfs, fn)
 			case 'Q':
-				fn, err := parseHostPattern(fqdnlong)
+				fn, err := parseHostPattern(fqdnlong, cfg)
 				if err != nil {
 					return nil, err
 				}
@@ -1121,14 +2594,13 @@ func parseIPv6(h *host, r *bytes.Reader) error {
 		r.ReadRune()
 	}
 	for i := 0; i < ip6len; i++ {
-		var j int
-		if err := parseInt(&j, 0, r, isHexa); err != nil {
-			return err
-		}
-		if j < 0 || j > 0xFFFF {
-			return ErrPattern
+		if str, _ := parseString(r, 0, isHexa); str != "" {
+			j, err := strconv.ParseUint(str, 16, 16)
+			if err != nil {
+				return ErrPattern
+			}
+			buf.WriteString(strconv.FormatUint(j, 16))
 		}
-		buf.WriteString(strconv.FormatInt(int64(j), 16))
 		if i < ip6len-1 {
 			if k := peek(r); k != ':' {
 				break
@@ -1147,6 +2619,11 @@ func parseIPv6(h *host, r *bytes.Reader) error {
 		}
 		r.ReadRune()
 	}
+	// an address has at least two colons (eg: ::1), a lone hexadecimal group
+	// is a word
+	if strings.Count(buf.String(), ":") < 2 {
+		return ErrPattern
+	}
 	h.Addr = buf.String()
 	return nil
 }
@@ -1171,24 +2648,28 @@ func parsePort(h *host, r *bytes.Reader) error {
 	return nil
 }
 
-func parseHostname(h *host, r *bytes.Reader) error {
-	h.Name, _ = parseString(r, 0, isAlpha)
-	return nil
+func parseHostname(accept func(rune) bool) hostfunc {
+	return func(h *host, r *bytes.Reader) error {
+		h.Name, _ = parseString(r, 0, accept)
+		return nil
+	}
 }
 
-func parseFQDN(h *host, r *bytes.Reader) error {
-	var buf bytes.Buffer
-	for {
-		part, _ := parseString(r, 0, isAlpha)
-		buf.WriteString(part)
-		if k := peek(r); k != '.' {
-			break
+func parseFQDN(accept func(rune) bool) hostfunc {
+	return func(h *host, r *bytes.Reader) error {
+		var buf bytes.Buffer
+		for {
+			part, _ := parseString(r, 0, accept)
+			buf.WriteString(part)
+			if k := peek(r); k != '.' {
+				break
+			}
+			buf.WriteRune('.')
+			r.ReadRune()
 		}
-		buf.WriteRune('.')
-		r.ReadRune()
+		h.Name = buf.String()
+		return nil
 	}
-	h.Name = buf.String()
-	return nil
 }
 
 func parseInt(i *int, n int, str io.RuneScanner, accept func(rune) bool) error {
@@ -1196,6 +2677,9 @@ func parseInt(i *int, n int, str io.RuneScanner, accept func(rune) bool) error {
 	for i := 0; n <= 0 || i < n; i++ {
 		r, _, err := str.ReadRune()
 		if err != nil {
+			if n == 0 && buf.Len() > 0 && errors.Is(err, io.EOF) {
+				break
+			}
 			return err
 		}
 		if !accept(r) {
@@ -1209,6 +2693,9 @@ func parseInt(i *int, n int, str io.RuneScanner, accept func(rune) bool) error {
 	}
 	part := strings.TrimLeft(buf.String(), "0")
 	if part == "" {
+		if buf.Len() > 0 {
+			*i = 0
+		}
 		return nil
 	}
 	x, err := strconv.ParseInt(part, 0, 64)
@@ -1222,11 +2709,11 @@ func parseString(r io.RuneScanner, length int, accept func(rune) bool) (string,
 	if accept == nil {
 		accept = func(_ rune) bool { return true }
 	}
-	defer r.UnreadRune()
 	var buf bytes.Buffer
 	for i := 0; length <= 0 || i < length; i++ {
 		c, _, _ := r.ReadRune()
 		if !accept(c) {
+			r.UnreadRune()
 			break
 		}
 		buf.WriteRune(c)
@@ -1259,6 +2746,26 @@ func isAlpha(r rune) bool {
 	return isDigit(r) || isLetter(r) || r == '-' || r == '_'
 }
 
+func isUnicodeLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r)
+}
+
+func isUnicodeAlpha(r rune) bool {
+	return unicode.IsDigit(r) || isUnicodeLetter(r) || r == '-' || r == '_'
+}
+
+func equalFold(w, g rune) bool {
+	if w == g {
+		return true
+	}
+	for f := unicode.SimpleFold(w); f != w; f = unicode.SimpleFold(f) {
+		if f == g {
+			return true
+		}
+	}
+	return false
+}
+
 func isBlank(r rune) bool {
 	return r == ' ' || r == '\t'
 }
@@ -0,0 +1,873 @@
+package log
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	brussels, err := time.LoadLocation("Europe/Brussels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		Name    string
+		Pattern string
+		Options []Option
+		Line    string
+		Want    Entry
+	}{
+		{
+			Name:    "syslog",
+			Pattern: "%t(%y-%m-%d %H:%M:%S) %h(%f) %n[%p]: %m",
+			Line:    "2021-03-14 10:22:33 web.local sshd[42]: session opened",
+			Want: Entry{
+				When:    time.Date(2021, 3, 14, 10, 22, 33, 0, time.UTC),
+				Host:    "web.local",
+				Process: "sshd",
+				Pid:     42,
+				Message: "session opened",
+			},
+		},
+		{
+			Name:    "regexp-fields",
+			Pattern: `%r((?P<method>[A-Z]+) (?P<path>\S+)) %m`,
+			Line:    "GET /index.html 200",
+			Want: Entry{
+				Fields:  map[string]string{"method": "GET", "path": "/index.html"},
+				Message: "200",
+			},
+		},
+		{
+			Name:    "priority",
+			Pattern: "%P%m",
+			Line:    "<34>boom",
+			Want: Entry{
+				Level:   "CRITICAL",
+				Fields:  map[string]string{"facility": "auth"},
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "levels",
+			Pattern: "%l %m",
+			Line:    "warn boom",
+			Want: Entry{
+				Level:   "WARNING",
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "levels-aliases",
+			Pattern: "%l %m",
+			Options: []Option{WithLevels(map[string]string{"x": "y", "y": "error", "z": "warn"})},
+			Line:    "X boom",
+			Want: Entry{
+				Level:   "Y",
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "levels-builtin",
+			Pattern: "%l %m",
+			Options: []Option{WithLevels(map[string]string{"x": "y", "y": "error", "z": "warn"})},
+			Line:    "z boom",
+			Want: Entry{
+				Level:   "WARNING",
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "unicode-name",
+			Pattern: "%n[%p]: %m",
+			Line:    "démon[1]: boom",
+			Want: Entry{
+				Process: "démon",
+				Pid:     1,
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "process-class",
+			Pattern: "%n([:name:]./)[%p]: %m",
+			Line:    "/usr/bin/app[3]: boom",
+			Want: Entry{
+				Process: "/usr/bin/app",
+				Pid:     3,
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "process-parenthesis",
+			Pattern: "%n(%p): %m",
+			Line:    "sshd(12): boom",
+			Want: Entry{
+				Process: "sshd",
+				Pid:     12,
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "process-literal",
+			Pattern: "%n(x)%m",
+			Line:    "xx(x)rest",
+			Want: Entry{
+				Process: "xx",
+				Message: "rest",
+			},
+		},
+		{
+			Name:    "user-class",
+			Pattern: "%u([:alpha:].) %m",
+			Line:    "john.doe boom",
+			Want: Entry{
+				User:    "john.doe",
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "hostname-class",
+			Pattern: "%h(%h([:alnum:].)) %m",
+			Line:    "web01.lan boom",
+			Want: Entry{
+				Host:    "web01.lan",
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "source",
+			Pattern: "%c %m",
+			Line:    "main.go:42 boom",
+			Want: Entry{
+				Source:     "main.go",
+				SourceLine: 42,
+				Message:    "boom",
+			},
+		},
+		{
+			Name:    "source-column",
+			Pattern: "%c:%*: %m",
+			Line:    "main.go:12:3: boom",
+			Want: Entry{
+				Source:     "main.go",
+				SourceLine: 12,
+				Message:    "boom",
+			},
+		},
+		{
+			Name:    "source-colon",
+			Pattern: "%c: %m",
+			Line:    "main.go:12: boom",
+			Want: Entry{
+				Source:     "main.go",
+				SourceLine: 12,
+				Message:    "boom",
+			},
+		},
+		{
+			Name:    "source-url",
+			Pattern: "%c %m",
+			Line:    "http://host:8080/main.go:42 boom",
+			Want: Entry{
+				Source:     "http://host:8080/main.go",
+				SourceLine: 42,
+				Message:    "boom",
+			},
+		},
+		{
+			Name:    "source-drive",
+			Pattern: "%c %m",
+			Line:    `C:\src\main.go:42 boom`,
+			Want: Entry{
+				Source:     `C:\src\main.go`,
+				SourceLine: 42,
+				Message:    "boom",
+			},
+		},
+		{
+			Name:    "search",
+			Pattern: "%n[%p]: %m",
+			Options: []Option{WithMode(MatchSearch)},
+			Line:    "garbage sshd[42]: boom",
+			Want: Entry{
+				Process: "sshd",
+				Pid:     42,
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "strict",
+			Pattern: "%n[%p]:",
+			Options: []Option{WithMode(MatchStrict)},
+			Line:    "sshd[42]:",
+			Want: Entry{
+				Process: "sshd",
+				Pid:     42,
+			},
+		},
+		{
+			Name:    "fold-case",
+			Pattern: "pid=%p msg=%m",
+			Options: []Option{WithFoldCase()},
+			Line:    "PID=42 MSG=boom",
+			Want: Entry{
+				Pid:     42,
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "loose-blanks",
+			Pattern: "%n: %m",
+			Options: []Option{WithLooseBlanks()},
+			Line:    "sshd: \t boom",
+			Want: Entry{
+				Process: "sshd",
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "discard-backtrack",
+			Pattern: "[%*] %l %m",
+			Line:    "[a] b] ERROR boom",
+			Want: Entry{
+				Level:   "ERROR",
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "discard-shortest",
+			Pattern: "%*: %m",
+			Line:    "a: b: c",
+			Want: Entry{
+				Message: "b: c",
+			},
+		},
+		{
+			Name:    "alternatives-rollback",
+			Pattern: "@(?<pid>%n[%p]|?<name>%n): %m",
+			Line:    "cron: started",
+			Want: Entry{
+				Process:  "cron",
+				Message:  "started",
+				Branches: []string{"name"},
+			},
+		},
+		{
+			Name:    "alternatives-discard",
+			Pattern: "@(%n[%p]|%u): %m",
+			Line:    "cron: started",
+			Want: Entry{
+				User:     "cron",
+				Message:  "started",
+				Branches: []string{"1"},
+			},
+		},
+		{
+			Name:    "alternatives-first",
+			Pattern: "@(?<pid>%n[%p]|?<name>%n): %m",
+			Line:    "cron[7]: started",
+			Want: Entry{
+				Process:  "cron",
+				Pid:      7,
+				Message:  "started",
+				Branches: []string{"pid"},
+			},
+		},
+		{
+			Name:    "macro",
+			Pattern: "%{proc}: %m",
+			Options: []Option{WithMacros(map[string]string{"proc": "%n[%p]"})},
+			Line:    "sshd[42]: boom",
+			Want: Entry{
+				Process: "sshd",
+				Pid:     42,
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "pid-end-of-line",
+			Pattern: "pid %p",
+			Line:    "pid 12",
+			Want: Entry{
+				Pid: 12,
+			},
+		},
+		{
+			Name:    "ipv4-end-of-line",
+			Pattern: "@(%h(%4)|%h(%6))",
+			Line:    "10.0.0.1",
+			Want: Entry{
+				Host:     "10.0.0.1",
+				Branches: []string{"0"},
+			},
+		},
+		{
+			Name:    "ipv4-port-end-of-line",
+			Pattern: "%h(%4:%p)",
+			Line:    "10.0.0.1:22",
+			Want: Entry{
+				Host: "10.0.0.1:22",
+			},
+		},
+		{
+			Name:    "ipv6",
+			Pattern: "%h(%6) %m",
+			Line:    "2001:db8::1 boom",
+			Want: Entry{
+				Host:    "2001:db8::1",
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "ipv6-end-of-line",
+			Pattern: "@(%h(%4)|%h(%6))",
+			Line:    "fe80::1",
+			Want: Entry{
+				Host:     "fe80::1",
+				Branches: []string{"1"},
+			},
+		},
+		{
+			Name:    "half-day",
+			Pattern: "%t(%-m/%-d/%y %-K:%M:%S %p) %m",
+			Line:    "3/7/2021 1:05:09 PM boom",
+			Want: Entry{
+				When:    time.Date(2021, 3, 7, 13, 5, 9, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "half-day-midnight",
+			Pattern: "%t(%-m/%-d/%y %-K:%M:%S %p) %m",
+			Line:    "3/7/2021 12:05:09 AM boom",
+			Want: Entry{
+				When:    time.Date(2021, 3, 7, 0, 5, 9, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "padded-day",
+			Pattern: "%t(%b %e %H:%M:%S %y) %m",
+			Line:    "Mar  7 10:00:00 2021 boom",
+			Want: Entry{
+				When:    time.Date(2021, 3, 7, 10, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "zone-offset",
+			Pattern: "%t(%I) %m",
+			Line:    "2021-07-01 10:00:00+02:00 boom",
+			Want: Entry{
+				When:    time.Date(2021, 7, 1, 8, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "zone-negative-offset",
+			Pattern: "%t(%I) %m",
+			Line:    "2021-07-01 10:00:00-03:30 boom",
+			Want: Entry{
+				When:    time.Date(2021, 7, 1, 13, 30, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "zone-abbreviation",
+			Pattern: "%t(%y-%m-%d %H:%M:%S %z) %m",
+			Line:    "2021-01-01 10:00:00 CET boom",
+			Want: Entry{
+				When:    time.Date(2021, 1, 1, 9, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "zone-iana",
+			Pattern: "%t(%y-%m-%d %H:%M:%S %z) %m",
+			Line:    "2021-07-01 10:00:00 Europe/Brussels boom",
+			Want: Entry{
+				When:    time.Date(2021, 7, 1, 10, 0, 0, 0, brussels),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "zone-location",
+			Pattern: "%t(%y-%m-%d %H:%M:%S) %m",
+			Options: []Option{WithLocation(brussels)},
+			Line:    "2021-07-01 10:00:00 boom",
+			Want: Entry{
+				When:    time.Date(2021, 7, 1, 8, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "leap-year-reference",
+			Pattern: "%t(%b %e %H:%M:%S) %m",
+			Options: []Option{WithYear(time.Date(2020, 3, 3, 0, 0, 0, 0, time.UTC))},
+			Line:    "Feb 29 12:00:00 boom",
+			Want: Entry{
+				When:    time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "previous-year-reference",
+			Pattern: "%t(%b %e %H:%M:%S) %m",
+			Options: []Option{WithYear(time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC))},
+			Line:    "Dec 30 12:00:00 boom",
+			Want: Entry{
+				When:    time.Date(2020, 12, 30, 12, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "epoch",
+			Pattern: "%t(%s) %m",
+			Line:    "1600000000.5 boom",
+			Want: Entry{
+				When:    time.Unix(1600000000, 5e8).UTC(),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "epoch-millis",
+			Pattern: "%t(%L) %m",
+			Line:    "1600000000123 boom",
+			Want: Entry{
+				When:    time.Unix(1600000000, 123e6).UTC(),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "epoch-reference",
+			Pattern: "%t(%U) %m",
+			Options: []Option{WithYear(time.Date(2000, 3, 3, 0, 0, 0, 0, time.UTC))},
+			Line:    "1600000000000001 boom",
+			Want: Entry{
+				When:    time.Unix(1600000000, 1000).UTC(),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "full-names",
+			Pattern: "%t(%A %e %B %y) %m",
+			Line:    "Monday  1 February 2021 boom",
+			Want: Entry{
+				When:    time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "locale",
+			Pattern: "%t(%e %B %y) %m",
+			Options: []Option{WithLocale("fr")},
+			Line:    " 1 février 2021 boom",
+			Want: Entry{
+				When:    time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "weekday-reference",
+			Pattern: "%t(%a %b %e %H:%M:%S) %m",
+			Options: []Option{WithYear(time.Date(2020, 3, 3, 0, 0, 0, 0, time.UTC))},
+			Line:    "Sat Feb 29 12:00:00 boom",
+			Want: Entry{
+				When:    time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "lenient",
+			Pattern: "%t(%y-%m-%d) %m",
+			Options: []Option{WithLenientTime()},
+			Line:    "2021-02-30 boom",
+			Want: Entry{
+				When:    time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "go-optional-fraction-missing",
+			Pattern: "%t(go:2006-01-02 15:04:05.999) %m",
+			Line:    "2021-04-01 12:00:00 boom",
+			Want: Entry{
+				When:    time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "go-optional-fraction",
+			Pattern: "%t(go:2006-01-02 15:04:05.999) %m",
+			Line:    "2021-04-01 12:00:00.25 boom",
+			Want: Entry{
+				When:    time.Date(2021, 4, 1, 12, 0, 0, 25e7, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "strftime",
+			Pattern: "%t(strftime:%F %T) %m",
+			Line:    "2021-04-01 12:00:00 boom",
+			Want: Entry{
+				When:    time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "relative",
+			Pattern: "[%t(%r)] %m",
+			Options: []Option{WithAnchor(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))},
+			Line:    "[   12.500000] boom",
+			Want: Entry{
+				When:    time.Date(2021, 1, 1, 0, 0, 12, 5e8, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "relative-reference",
+			Pattern: "[%t(%r)] %m",
+			Options: []Option{
+				WithAnchor(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
+				WithYear(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+			},
+			Line: "[3600] boom",
+			Want: Entry{
+				When:    time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC),
+				Message: "boom",
+			},
+		},
+		{
+			Name:    "regexp-word",
+			Pattern: `%r(\d+) %m`,
+			Line:    "123 abc",
+			Want: Entry{
+				Words:   []string{"123"},
+				Message: "abc",
+			},
+		},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			p, err := Compile(d.Pattern, d.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error compiling %s: %s", d.Pattern, err)
+			}
+			got, err := p.MatchString(d.Line)
+			if err != nil {
+				t.Fatalf("unexpected error matching %q: %s", d.Line, err)
+			}
+			got.Line, got.stamp = "", nil
+			if !got.When.Equal(d.Want.When) {
+				t.Errorf("time mismatched! want %s, got %s", d.Want.When, got.When)
+			}
+			got.When = d.Want.When
+			if !reflect.DeepEqual(got, d.Want) {
+				t.Errorf("entry mismatched!\nwant: %+v\ngot:  %+v", d.Want, got)
+			}
+		})
+	}
+}
+
+func TestMatchError(t *testing.T) {
+	data := []struct {
+		Name    string
+		Pattern string
+		Options []Option
+		Line    string
+	}{
+		{
+			Name:    "priority",
+			Pattern: "%P%m",
+			Line:    "<192>boom",
+		},
+		{
+			Name:    "ascii-name",
+			Pattern: "%n[%p]: %m",
+			Options: []Option{WithASCII()},
+			Line:    "démon[1]: boom",
+		},
+		{
+			Name:    "source",
+			Pattern: "%c %m",
+			Line:    "main.go boom",
+		},
+		{
+			Name:    "strict",
+			Pattern: "%n:",
+			Options: []Option{WithMode(MatchStrict)},
+			Line:    "sshd: boom",
+		},
+		{
+			Name:    "not-search",
+			Pattern: "%n[%p]: %m",
+			Line:    "garbage sshd[42]: boom",
+		},
+		{
+			Name:    "case",
+			Pattern: "pid=%p msg=%m",
+			Line:    "PID=42 MSG=boom",
+		},
+		{
+			Name:    "discard",
+			Pattern: "[%*] %l %m",
+			Line:    "[a b boom",
+		},
+		{
+			Name:    "alternatives",
+			Pattern: "@(%n[%p]|%p): %m",
+			Line:    "cron: boom",
+		},
+		{
+			Name:    "ipv6-group",
+			Pattern: "%h(%6) %m",
+			Line:    "cafe babe",
+		},
+		{
+			Name:    "half-day",
+			Pattern: "%t(%K:%M %p)",
+			Line:    "10:05 XM",
+		},
+		{
+			Name:    "zone-local",
+			Pattern: "%t(%y-%m-%d %z)",
+			Line:    "2021-04-01 Local",
+		},
+		{
+			Name:    "zone-ambiguous",
+			Pattern: "%t(%y-%m-%d %z)",
+			Line:    "2021-04-01 CST",
+		},
+		{
+			Name:    "zone-unknown",
+			Pattern: "%t(%y-%m-%d %z)",
+			Line:    "2021-04-01 Foo/Bar",
+		},
+		{
+			Name:    "leap-year-reference",
+			Pattern: "%t(%b %e %H:%M:%S) %m",
+			Options: []Option{WithYear(time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC))},
+			Line:    "Feb 29 12:00:00 boom",
+		},
+		{
+			Name:    "month",
+			Pattern: "%t(%y-%m-%d)",
+			Line:    "2021-13-01",
+		},
+		{
+			Name:    "day",
+			Pattern: "%t(%y-%m-%d)",
+			Line:    "2021-02-30",
+		},
+		{
+			Name:    "weekday-reference",
+			Pattern: "%t(%a %b %e %H:%M:%S) %m",
+			Options: []Option{WithYear(time.Date(2020, 3, 3, 0, 0, 0, 0, time.UTC))},
+			Line:    "Mon Feb 29 12:00:00 boom",
+		},
+		{
+			Name:    "weekday",
+			Pattern: "%t(%a %y-%m-%d)",
+			Line:    "Mon 2021-04-01",
+		},
+		{
+			Name:    "fraction",
+			Pattern: "%t(go:2006-01-02 15:04:05.000) %m",
+			Line:    "2021-04-01 12:00:00 boom",
+		},
+		{
+			Name:    "literal",
+			Pattern: "%n[%p]: %m",
+			Line:    "sshd 42 boom",
+		},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			p, err := Compile(d.Pattern, d.Options...)
+			if err != nil {
+				t.Fatalf("unexpected error compiling %s: %s", d.Pattern, err)
+			}
+			if _, err := p.MatchString(d.Line); !errors.Is(err, ErrPattern) {
+				t.Errorf("%q: expected ErrPattern, got %v", d.Line, err)
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	data := []struct {
+		Name    string
+		Pattern string
+		Options []Option
+	}{
+		{Name: "empty", Pattern: ""},
+		{Name: "unknown-specifier", Pattern: "%Q"},
+		{Name: "regexp", Pattern: "%r(()"},
+		{Name: "macro", Pattern: "%{proc}"},
+		{Name: "macro-recursive", Pattern: "%{a}", Options: []Option{WithMacros(map[string]string{"a": "%{b}", "b": "%{a}"})}},
+		{Name: "unclosed-alternatives", Pattern: "@(%n|%p"},
+		{Name: "class", Pattern: "%n([:foo:])"},
+		{Name: "go-layout", Pattern: "%t(go:06-01-02)"},
+		{Name: "strftime", Pattern: "%t(strftime:%Q)"},
+		{Name: "locale", Pattern: "%t(%B)", Options: []Option{WithLocale("xx")}},
+		{Name: "levels", Pattern: "%l", Options: []Option{WithLevels(map[string]string{"x": ""})}},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			_, err := Compile(d.Pattern, d.Options...)
+			if !errors.Is(err, ErrSyntax) {
+				t.Errorf("%s: expected ErrSyntax, got %v", d.Pattern, err)
+			}
+		})
+	}
+}
+
+func TestMultiReader(t *testing.T) {
+	const lines = "GET /x 200\nnoise here\nsshd[1]: boom\n"
+	patterns := []NamedPattern{
+		{Name: "access", Pattern: "%w %w %p"},
+		{Name: "syslog", Pattern: "%n[%p]: %m"},
+	}
+	r, err := NewMultiReader(strings.NewReader(lines), patterns, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	es, err := r.ReadAll()
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []Entry{
+		{
+			Words:   []string{"GET", "/x"},
+			Pid:     200,
+			Pattern: "access",
+		},
+		{
+			Process: "sshd",
+			Pid:     1,
+			Message: "boom",
+			Pattern: "syslog",
+		},
+	}
+	if len(es) != len(want) {
+		t.Fatalf("entries mismatched! want %d, got %d", len(want), len(es))
+	}
+	for i := range es {
+		es[i].Line = ""
+		if !reflect.DeepEqual(es[i], want[i]) {
+			t.Errorf("entry mismatched!\nwant: %+v\ngot:  %+v", want[i], es[i])
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	const lines = "Dec 30 10:00:00 a\nFeb 29 10:00:00 b\nMar  1 10:00:00 c\n"
+	data := []struct {
+		Name string
+		Year time.Time
+		Want []time.Time
+	}{
+		{
+			Name: "common-year",
+			Year: time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC),
+			Want: []time.Time{
+				time.Date(2020, 12, 30, 10, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Name: "leap-year",
+			Year: time.Date(2020, 3, 3, 0, 0, 0, 0, time.UTC),
+			Want: []time.Time{
+				time.Date(2019, 12, 30, 10, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 29, 10, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	p := MustCompile("%t(%b %e %H:%M:%S) %m")
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			r, err := NewPatternReader(strings.NewReader(lines), p, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.SetYear(d.Year)
+			es, _ := r.ReadAll()
+			if len(es) != len(d.Want) {
+				t.Fatalf("entries mismatched! want %d, got %d", len(d.Want), len(es))
+			}
+			for i, e := range es {
+				if !e.When.Equal(d.Want[i]) {
+					t.Errorf("time mismatched! want %s, got %s", d.Want[i], e.When)
+				}
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	data := []struct {
+		Pattern string
+		Want    Schema
+	}{
+		{
+			Pattern: "%t(%y-%m-%d %H:%M:%S) %n[%p]: %m",
+			Want: Schema{
+				Time:    true,
+				Layout:  "%y-%m-%d %H:%M:%S",
+				Pid:     true,
+				Process: true,
+				Message: true,
+			},
+		},
+		{
+			Pattern: `%r((?P<method>[A-Z]+) (?P<path>\S+)) %w %m`,
+			Want: Schema{
+				Message: true,
+				Words:   1,
+				Fields:  []string{"method", "path"},
+			},
+		},
+	}
+	for _, d := range data {
+		got := MustCompile(d.Pattern).Schema()
+		if !reflect.DeepEqual(got, d.Want) {
+			t.Errorf("%s: schema mismatched!\nwant: %+v\ngot:  %+v", d.Pattern, d.Want, got)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	data := []struct {
+		Pattern string
+		Want    string
+	}{
+		{Pattern: "%c: %m", Want: "main.go:42: boom\n"},
+		{Pattern: "%f(%L) %m", Want: "main.go(42) boom\n"},
+	}
+	e := Entry{
+		Source:     "main.go",
+		SourceLine: 42,
+		Message:    "boom",
+	}
+	for _, d := range data {
+		var buf strings.Builder
+		w, err := NewWriter(&buf, d.Pattern)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", d.Pattern, err)
+			continue
+		}
+		if err := w.Write(e); err != nil {
+			t.Errorf("%s: unexpected error: %s", d.Pattern, err)
+			continue
+		}
+		if got := buf.String(); got != d.Want {
+			t.Errorf("%s: output mismatched! want %q, got %q", d.Pattern, d.Want, got)
+		}
+	}
+}